@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/georgi-georgiev/blunder"
+	"github.com/georgi-georgiev/passport/responses"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type AuditHandlers struct {
+	service *AuditService
+	log     *zap.Logger
+	blunder *blunder.Blunder
+}
+
+func NewAuditHandlers(service *AuditService, log *zap.Logger, blunder *blunder.Blunder) *AuditHandlers {
+	return &AuditHandlers{service: service, log: log, blunder: blunder}
+}
+
+// GetEventsHandler godoc
+// @Summary Get audit events
+// @Description Lists security audit events newest first, pass nextCursor of the response as cursor to get the next page
+// @Tags identity
+// @Produce  json
+// @Security OAuth2Application
+// @Param userId query string false "user id, matches actor or subject"
+// @Param action query string false "action" example(user.login)
+// @Param from query string false "RFC 3339 time, inclusive"
+// @Param to query string false "RFC 3339 time, exclusive"
+// @Param cursor query string false "cursor"
+// @Param limit query int false "page size, up to 200"
+// @Success 200 {object} responses.AuditEventsResponse
+// @Failure      400  {object}  blunder.HTTPErrorResponse
+// @Router /audit [get]
+func (h *AuditHandlers) GetEvents(c *gin.Context) {
+	filter := Filter{UserID: c.Query("userId"), Action: c.Query("action")}
+
+	var err error
+
+	if from := c.Query("from"); from != "" {
+		filter.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, blunder.BadRequest())
+			return
+		}
+	}
+
+	if to := c.Query("to"); to != "" {
+		filter.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, blunder.BadRequest())
+			return
+		}
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		filter.Cursor, err = primitive.ObjectIDFromHex(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, blunder.BadRequest())
+			return
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, blunder.BadRequest())
+			return
+		}
+	}
+
+	events, nextCursor, err := h.service.GetEvents(c.Request.Context(), filter)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	response := responses.AuditEventsResponse{Events: make([]responses.AuditEventResponse, 0), NextCursor: nextCursor}
+	for _, event := range events {
+		response.Events = append(response.Events, MapToAuditEventResponse(event))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func MapToAuditEventResponse(event *Event) responses.AuditEventResponse {
+	return responses.AuditEventResponse{
+		ID:            event.ID.Hex(),
+		CreatedOn:     event.CreatedOn,
+		Action:        event.Action,
+		Outcome:       event.Outcome,
+		ActorID:       event.ActorID,
+		SubjectID:     event.SubjectID,
+		ClientID:      event.ClientID,
+		IP:            event.IP,
+		UserAgent:     event.UserAgent,
+		CorrelationID: event.CorrelationID,
+		Reason:        event.Reason,
+		Details:       event.Details,
+		Sequence:      event.Sequence,
+		Hash:          event.Hash,
+	}
+}
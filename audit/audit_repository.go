@@ -0,0 +1,178 @@
+package audit
+
+import (
+	"context"
+	"sort"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditRepository is append-only, it does not embed the generic repository so events can not be updated or deleted through it
+type AuditRepository struct {
+	repository *passport.MongoRepository
+}
+
+func NewAuditRepository(client *mongo.Client, conf *passport.Config) *AuditRepository {
+	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "audit_events")
+
+	actorIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "_id", Value: -1}},
+	}
+
+	subjectIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "subjectId", Value: 1}, {Key: "_id", Value: -1}},
+	}
+
+	actionIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "action", Value: 1}, {Key: "_id", Value: -1}},
+	}
+
+	createdIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "createdOn", Value: -1}},
+	}
+
+	// only one event can take a place in the chain, events recorded before chaining have no day
+	chainIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "chainDay", Value: 1}, {Key: "sequence", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"chainDay": bson.M{"$exists": true}}),
+	}
+
+	_, err := repository.Collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{actorIndex, subjectIndex, actionIndex, createdIndex, chainIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	return &AuditRepository{repository}
+}
+
+func (r *AuditRepository) Append(ctx context.Context, event *Event) error {
+	_, err := r.repository.Create(ctx, event)
+	return err
+}
+
+// GetChainHead returns the last event in the chain of the day, nil when the chain is empty
+func (r *AuditRepository) GetChainHead(ctx context.Context, day string) (*Event, error) {
+	event := &Event{}
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}})
+
+	err := r.repository.Collection.FindOne(ctx, bson.M{"chainDay": day}, opts).Decode(event)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// WalkChain calls fn for events of the day in chain order, stopping at the first error
+func (r *AuditRepository) WalkChain(ctx context.Context, day string, fn func(event *Event) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}})
+
+	cursor, err := r.repository.Collection.Find(ctx, bson.M{"chainDay": day}, opts)
+	if err != nil {
+		return err
+	}
+
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		event := &Event{}
+
+		err = cursor.Decode(event)
+		if err != nil {
+			return err
+		}
+
+		err = fn(event)
+		if err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// GetChainDays lists days having chained events within the inclusive range, empty bounds are open
+func (r *AuditRepository) GetChainDays(ctx context.Context, from string, to string) ([]string, error) {
+	return distinctChainDays(ctx, r.repository, from, to)
+}
+
+func distinctChainDays(ctx context.Context, repository *passport.MongoRepository, from string, to string) ([]string, error) {
+	chainDay := bson.M{"$exists": true}
+	if from != "" {
+		chainDay["$gte"] = from
+	}
+
+	if to != "" {
+		chainDay["$lte"] = to
+	}
+
+	values, err := repository.Collection.Distinct(ctx, "chainDay", bson.M{"chainDay": chainDay})
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]string, 0, len(values))
+	for _, value := range values {
+		if day, ok := value.(string); ok {
+			days = append(days, day)
+		}
+	}
+
+	sort.Strings(days)
+
+	return days, nil
+}
+
+// Find returns page of events matching the filter, newest first
+func (r *AuditRepository) Find(ctx context.Context, filter Filter) ([]*Event, error) {
+	query := bson.M{}
+
+	if filter.UserID != "" {
+		query["$or"] = bson.A{bson.M{"actorId": filter.UserID}, bson.M{"subjectId": filter.UserID}}
+	}
+
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+
+	createdOn := bson.M{}
+	if !filter.From.IsZero() {
+		createdOn["$gte"] = filter.From
+	}
+
+	if !filter.To.IsZero() {
+		createdOn["$lt"] = filter.To
+	}
+
+	if len(createdOn) > 0 {
+		query["createdOn"] = createdOn
+	}
+
+	if !filter.Cursor.IsZero() {
+		query["_id"] = bson.M{"$lt": filter.Cursor}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(filter.Limit))
+
+	cursor, err := r.repository.Collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Event, 0)
+
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
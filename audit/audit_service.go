@@ -0,0 +1,224 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"github.com/golang-jwt/jwt"
+	"github.com/rotisserie/eris"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+	// replicas race for the next place in the chain, the loser reads the new head and tries again
+	maxAppendAttempts = 10
+	// events that could not be appended wait here to be retried, recording blocks once it is full
+	pendingQueueSize = 1000
+	retryDelay       = time.Second
+)
+
+type AuditService struct {
+	repository           *AuditRepository
+	checkpointRepository *CheckpointRepository
+	keyManager           *passport.KeyManager
+	conf                 *passport.Config
+	log                  *zap.Logger
+	pending              chan *Event
+}
+
+func NewAuditService(repository *AuditRepository, checkpointRepository *CheckpointRepository, keyManager *passport.KeyManager, conf *passport.Config, log *zap.Logger) *AuditService {
+	return &AuditService{repository: repository, checkpointRepository: checkpointRepository, keyManager: keyManager, conf: conf, log: log, pending: make(chan *Event, pendingQueueSize)}
+}
+
+// Record appends the event to the chain of the day, filling caller of the request from context. The audited action
+// itself already happened, so event that can not be appended right away is queued and retried in the background.
+func (s *AuditService) Record(ctx context.Context, event *Event) {
+	info := passport.RequestInfoFrom(ctx)
+
+	event.ID = primitive.NewObjectID()
+	// mongo keeps milliseconds, hash has to be computed from what is stored
+	event.CreatedOn = time.Now().UTC().Truncate(time.Millisecond)
+	event.ChainDay = ChainDay(event.CreatedOn)
+	event.IP = info.IP
+	event.UserAgent = info.UserAgent
+	event.CorrelationID = info.CorrelationID
+
+	if event.ActorID == "" {
+		event.ActorID = info.ActorID
+	}
+
+	if event.ClientID == "" {
+		event.ClientID = info.ClientID
+	}
+
+	err := s.append(ctx, event)
+	if err == nil {
+		return
+	}
+
+	s.log.With(zap.Error(err)).Warn("could not record audit event, queued for retry", zap.String("action", event.Action), zap.String("outcome", event.Outcome), zap.String("subjectId", event.SubjectID))
+
+	select {
+	case s.pending <- event:
+	case <-ctx.Done():
+		s.log.Error("audit event lost, request ended while retry queue was full", zap.String("eventId", event.ID.Hex()), zap.String("action", event.Action), zap.String("outcome", event.Outcome), zap.String("subjectId", event.SubjectID))
+	}
+}
+
+func (s *AuditService) append(ctx context.Context, event *Event) error {
+	var err error
+	for attempt := 0; attempt < maxAppendAttempts; attempt++ {
+		err = s.appendToChain(ctx, event)
+		if !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+
+	return err
+}
+
+// retryPending appends queued events one by one, retrying each until it is stored
+func (s *AuditService) retryPending() {
+	for event := range s.pending {
+		for {
+			err := s.append(context.Background(), event)
+			if err == nil {
+				break
+			}
+
+			s.log.With(zap.Error(err)).Error("could not record queued audit event", zap.String("eventId", event.ID.Hex()), zap.Int("queued", len(s.pending)))
+			time.Sleep(retryDelay)
+		}
+	}
+}
+
+func (s *AuditService) appendToChain(ctx context.Context, event *Event) error {
+	head, err := s.repository.GetChainHead(ctx, event.ChainDay)
+	if err != nil {
+		return eris.Wrap(err, "could not get audit chain head")
+	}
+
+	event.Sequence = 1
+	event.PrevHash = GenesisHash(event.ChainDay)
+
+	if head != nil {
+		event.Sequence = head.Sequence + 1
+		event.PrevHash = head.Hash
+	}
+
+	event.Hash = event.ComputeHash()
+
+	return s.repository.Append(ctx, event)
+}
+
+// Checkpoint signs the current head of the day chain unless it is signed already
+func (s *AuditService) Checkpoint(ctx context.Context, day string) error {
+	head, err := s.repository.GetChainHead(ctx, day)
+	if err != nil {
+		return eris.Wrap(err, "could not get audit chain head")
+	}
+
+	if head == nil {
+		return nil
+	}
+
+	latest, err := s.checkpointRepository.GetLatest(ctx, day)
+	if err != nil {
+		return eris.Wrap(err, "could not get latest audit checkpoint")
+	}
+
+	if latest != nil && latest.Sequence >= head.Sequence {
+		return nil
+	}
+
+	signature, err := s.keyManager.Sign(&CheckpointClaims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:   s.conf.Token.Issuer,
+			IssuedAt: time.Now().UTC().Unix(),
+		},
+		ChainDay: head.ChainDay,
+		Sequence: head.Sequence,
+		Hash:     head.Hash,
+	})
+	if err != nil {
+		return eris.Wrap(err, "could not sign audit checkpoint")
+	}
+
+	err = s.checkpointRepository.Append(ctx, NewCheckpoint(head, signature))
+
+	// another replica signed the same head
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+
+	if err != nil {
+		return eris.Wrap(err, "could not store audit checkpoint")
+	}
+
+	return nil
+}
+
+// Scheduler retries queued events and periodically signs heads of today and yesterday chains, yesterday catches events
+// recorded right before midnight
+func (s *AuditService) Scheduler() {
+	go s.retryPending()
+
+	if s.conf.Audit.CheckpointInterval == 0 {
+		return
+	}
+
+	go func() {
+		for {
+			time.Sleep(s.conf.Audit.CheckpointInterval)
+
+			now := time.Now().UTC()
+
+			for _, day := range []string{ChainDay(now.AddDate(0, 0, -1)), ChainDay(now)} {
+				err := s.Checkpoint(context.Background(), day)
+				if err != nil {
+					s.log.With(zap.Error(err)).Error("could not checkpoint audit chain", zap.String("day", day))
+				}
+			}
+		}
+	}()
+}
+
+func (s *AuditService) Success(ctx context.Context, action string, subjectID string, details map[string]string) {
+	s.Record(ctx, &Event{Action: action, Outcome: OutcomeSuccess, SubjectID: subjectID, Details: details})
+}
+
+func (s *AuditService) Failure(ctx context.Context, action string, subjectID string, reason string) {
+	s.Record(ctx, &Event{Action: action, Outcome: OutcomeFailure, SubjectID: subjectID, Reason: reason})
+}
+
+// GetEvents returns page of events and cursor of the next page, empty on the last page
+func (s *AuditService) GetEvents(ctx context.Context, filter Filter) ([]*Event, string, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultPageSize
+	}
+
+	if filter.Limit > maxPageSize {
+		filter.Limit = maxPageSize
+	}
+
+	pageSize := filter.Limit
+	filter.Limit++
+
+	events, err := s.repository.Find(ctx, filter)
+	if err != nil {
+		return nil, "", eris.Wrap(err, "could not get audit events")
+	}
+
+	if len(events) <= pageSize {
+		return events, "", nil
+	}
+
+	events = events[:pageSize]
+
+	return events, events[pageSize-1].ID.Hex(), nil
+}
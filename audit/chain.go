@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+const chainDayLayout = "2006-01-02"
+
+// ChainDay returns the day chain the event created at t belongs to
+func ChainDay(t time.Time) string {
+	return t.UTC().Format(chainDayLayout)
+}
+
+// GenesisHash is the previous hash of the first event of the day
+func GenesisHash(day string) string {
+	sum := sha256.Sum256([]byte("passport audit chain " + day))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashedEvent fixes field order and time precision so the hash survives round trip through mongo
+type hashedEvent struct {
+	ID            string            `json:"id"`
+	CreatedOn     int64             `json:"createdOn"`
+	Action        string            `json:"action"`
+	Outcome       string            `json:"outcome"`
+	ActorID       string            `json:"actorId"`
+	SubjectID     string            `json:"subjectId"`
+	ClientID      string            `json:"clientId"`
+	IP            string            `json:"ip"`
+	UserAgent     string            `json:"userAgent"`
+	CorrelationID string            `json:"correlationId"`
+	Reason        string            `json:"reason"`
+	Details       map[string]string `json:"details"`
+	ChainDay      string            `json:"chainDay"`
+	Sequence      int64             `json:"sequence"`
+	PrevHash      string            `json:"prevHash"`
+}
+
+// ComputeHash hashes content of the event together with hash of the previous event
+func (e *Event) ComputeHash() string {
+	details := e.Details
+	if len(details) == 0 {
+		details = nil
+	}
+
+	// encoding can not fail for strings, map keys are encoded sorted
+	content, _ := json.Marshal(hashedEvent{
+		ID:            e.ID.Hex(),
+		CreatedOn:     e.CreatedOn.UnixMilli(),
+		Action:        e.Action,
+		Outcome:       e.Outcome,
+		ActorID:       e.ActorID,
+		SubjectID:     e.SubjectID,
+		ClientID:      e.ClientID,
+		IP:            e.IP,
+		UserAgent:     e.UserAgent,
+		CorrelationID: e.CorrelationID,
+		Reason:        e.Reason,
+		Details:       details,
+		ChainDay:      e.ChainDay,
+		Sequence:      e.Sequence,
+		PrevHash:      e.PrevHash,
+	})
+
+	sum := sha256.Sum256(content)
+
+	return hex.EncodeToString(sum[:])
+}
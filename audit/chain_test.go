@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testEvent() *Event {
+	id, _ := primitive.ObjectIDFromHex("65a1b2c3d4e5f60718293a4b")
+
+	return &Event{
+		ID:        id,
+		CreatedOn: time.Date(2024, 1, 15, 10, 30, 0, 123456789, time.UTC),
+		Action:    ActionLogin,
+		Outcome:   OutcomeSuccess,
+		ActorID:   "user-1",
+		IP:        "10.0.0.1",
+		ChainDay:  "2024-01-15",
+		Sequence:  1,
+		PrevHash:  GenesisHash("2024-01-15"),
+	}
+}
+
+func TestComputeHashStable(t *testing.T) {
+	event := testEvent()
+	hash := event.ComputeHash()
+
+	if len(hash) != 64 {
+		t.Fatalf("expected hex sha256, got %s", hash)
+	}
+
+	if event.ComputeHash() != hash {
+		t.Fatal("expected hash to be stable")
+	}
+
+	// mongo drops empty details and keeps only milliseconds
+	roundTripped := testEvent()
+	roundTripped.Details = map[string]string{}
+	roundTripped.CreatedOn = roundTripped.CreatedOn.Truncate(time.Millisecond)
+	roundTripped.Hash = "stored hash is not part of the content"
+
+	if roundTripped.ComputeHash() != hash {
+		t.Fatal("expected hash to survive round trip")
+	}
+
+	ordered := testEvent()
+	ordered.Details = map[string]string{"a": "1", "b": "2"}
+
+	reversed := testEvent()
+	reversed.Details = map[string]string{"b": "2", "a": "1"}
+
+	if ordered.ComputeHash() != reversed.ComputeHash() {
+		t.Fatal("expected hash not to depend on details order")
+	}
+}
+
+func TestComputeHashCoversContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(e *Event)
+	}{
+		{name: "action", change: func(e *Event) { e.Action = ActionLogout }},
+		{name: "outcome", change: func(e *Event) { e.Outcome = OutcomeFailure }},
+		{name: "actor", change: func(e *Event) { e.ActorID = "user-2" }},
+		{name: "subject", change: func(e *Event) { e.SubjectID = "user-2" }},
+		{name: "ip", change: func(e *Event) { e.IP = "10.0.0.2" }},
+		{name: "reason", change: func(e *Event) { e.Reason = "locked" }},
+		{name: "details", change: func(e *Event) { e.Details = map[string]string{"role": "admin"} }},
+		{name: "created on", change: func(e *Event) { e.CreatedOn = e.CreatedOn.Add(time.Millisecond) }},
+		{name: "sequence", change: func(e *Event) { e.Sequence = 2 }},
+		{name: "previous hash", change: func(e *Event) { e.PrevHash = GenesisHash("2024-01-16") }},
+	}
+
+	hash := testEvent().ComputeHash()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := testEvent()
+			tt.change(event)
+
+			if event.ComputeHash() == hash {
+				t.Fatalf("expected change of %s to change the hash", tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Checkpoint is signed statement of the chain head, events up to the sequence can not be altered without breaking it
+type Checkpoint struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	CreatedOn time.Time          `bson:"createdOn"`
+	ChainDay  string             `bson:"chainDay"`
+	Sequence  int64              `bson:"sequence"`
+	Hash      string             `bson:"hash"`
+	// Signature is jwt with CheckpointClaims signed by the service signing key
+	Signature string `bson:"signature"`
+}
+
+type CheckpointClaims struct {
+	jwt.StandardClaims
+	ChainDay string `json:"day"`
+	Sequence int64  `json:"seq"`
+	Hash     string `json:"hash"`
+}
+
+func (c CheckpointClaims) Valid() error {
+	return c.StandardClaims.Valid()
+}
+
+func NewCheckpoint(head *Event, signature string) *Checkpoint {
+	return &Checkpoint{
+		ID:        primitive.NewObjectID(),
+		CreatedOn: time.Now().UTC(),
+		ChainDay:  head.ChainDay,
+		Sequence:  head.Sequence,
+		Hash:      head.Hash,
+		Signature: signature,
+	}
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CheckpointRepository is append-only like the audit log it signs
+type CheckpointRepository struct {
+	repository *passport.MongoRepository
+}
+
+func NewCheckpointRepository(client *mongo.Client, conf *passport.Config) *CheckpointRepository {
+	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "audit_checkpoints")
+
+	chainIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "chainDay", Value: 1}, {Key: "sequence", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := repository.Collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{chainIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	return &CheckpointRepository{repository}
+}
+
+func (r *CheckpointRepository) Append(ctx context.Context, checkpoint *Checkpoint) error {
+	_, err := r.repository.Create(ctx, checkpoint)
+	return err
+}
+
+// GetLatest returns checkpoint with the highest sequence of the day, nil when the day has none
+func (r *CheckpointRepository) GetLatest(ctx context.Context, day string) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{}
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}})
+
+	err := r.repository.Collection.FindOne(ctx, bson.M{"chainDay": day}, opts).Decode(checkpoint)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return checkpoint, nil
+}
+
+// GetByDay returns checkpoints of the day ordered by sequence
+func (r *CheckpointRepository) GetByDay(ctx context.Context, day string) ([]*Checkpoint, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}})
+
+	cursor, err := r.repository.Collection.Find(ctx, bson.M{"chainDay": day}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Checkpoint, 0)
+
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetChainDays lists days having checkpoints within the inclusive range, empty bounds are open
+func (r *CheckpointRepository) GetChainDays(ctx context.Context, from string, to string) ([]string, error) {
+	return distinctChainDays(ctx, r.repository, from, to)
+}
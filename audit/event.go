@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ActionLogin         = "user.login"
+	ActionLogout        = "user.logout"
+	ActionUserCreate    = "user.create"
+	ActionUserUpdate    = "user.update"
+	ActionUserDelete    = "user.delete"
+	ActionUserUnlock    = "user.unlock"
+	ActionPasswordReset = "user.password_reset"
+	ActionImpersonate   = "user.impersonate"
+	ActionRoleCreate    = "role.create"
+	ActionRoleUpdate    = "role.update"
+	ActionRightCreate   = "right.create"
+	ActionRightUpdate   = "right.update"
+	ActionAuthenticate  = "request.authenticate"
+	ActionAuthorize     = "request.authorize"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Event records one security relevant action, events are never updated or deleted
+type Event struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	CreatedOn     time.Time          `bson:"createdOn"`
+	Action        string             `bson:"action"`
+	Outcome       string             `bson:"outcome"`
+	ActorID       string             `bson:"actorId,omitempty"`
+	SubjectID     string             `bson:"subjectId,omitempty"`
+	ClientID      string             `bson:"clientId,omitempty"`
+	IP            string             `bson:"ip,omitempty"`
+	UserAgent     string             `bson:"userAgent,omitempty"`
+	CorrelationID string             `bson:"correlationId,omitempty"`
+	Reason        string             `bson:"reason,omitempty"`
+	Details       map[string]string  `bson:"details,omitempty"`
+	// events of one day form a chain, each one carrying hash of the previous one
+	ChainDay string `bson:"chainDay,omitempty"`
+	Sequence int64  `bson:"sequence,omitempty"`
+	PrevHash string `bson:"prevHash,omitempty"`
+	Hash     string `bson:"hash,omitempty"`
+}
+
+// Filter narrows down events returned by query, zero values match everything
+type Filter struct {
+	// UserID matches events where the user is either actor or subject
+	UserID string
+	Action string
+	From   time.Time
+	To     time.Time
+	// Cursor is id of the last event of previous page
+	Cursor primitive.ObjectID
+	Limit  int
+}
@@ -0,0 +1,214 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/rotisserie/eris"
+)
+
+// BrokenLinkError points at the first place where the chain does not hold
+type BrokenLinkError struct {
+	ChainDay string
+	Sequence int64
+	EventID  string
+	Reason   string
+}
+
+func (e *BrokenLinkError) Error() string {
+	if e.EventID == "" {
+		return fmt.Sprintf("chain %s broken at sequence %d: %s", e.ChainDay, e.Sequence, e.Reason)
+	}
+
+	return fmt.Sprintf("chain %s broken at sequence %d (event %s): %s", e.ChainDay, e.Sequence, e.EventID, e.Reason)
+}
+
+type VerificationReport struct {
+	Days        int
+	Events      int64
+	Checkpoints int
+	// UnsignedDays are past days without checkpoint, events at their end could be removed unnoticed
+	UnsignedDays []string
+	// EmptyDays are days between the first and the last verified day with neither events nor checkpoints,
+	// the whole day could have been removed
+	EmptyDays []string
+}
+
+// Verifier walks the audit chains recomputing hashes and checking them against signed checkpoints
+type Verifier struct {
+	repository           *AuditRepository
+	checkpointRepository *CheckpointRepository
+	keyfunc              jwt.Keyfunc
+}
+
+func NewVerifier(repository *AuditRepository, checkpointRepository *CheckpointRepository, keyfunc jwt.Keyfunc) *Verifier {
+	return &Verifier{repository: repository, checkpointRepository: checkpointRepository, keyfunc: keyfunc}
+}
+
+// Verify checks chains of days within the inclusive range, returning *BrokenLinkError for the first broken link
+func (v *Verifier) Verify(ctx context.Context, from string, to string) (*VerificationReport, error) {
+	eventDays, err := v.repository.GetChainDays(ctx, from, to)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get audit chain days")
+	}
+
+	// days whose events were all removed are still known from their checkpoints
+	checkpointDays, err := v.checkpointRepository.GetChainDays(ctx, from, to)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get audit checkpoint days")
+	}
+
+	days := mergeDays(eventDays, checkpointDays)
+
+	today := ChainDay(time.Now())
+	report := &VerificationReport{UnsignedDays: make([]string, 0), EmptyDays: missingDays(days)}
+
+	for _, day := range days {
+		events, checkpoints, err := v.verifyDay(ctx, day)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Days++
+		report.Events += events
+		report.Checkpoints += checkpoints
+
+		if checkpoints == 0 && day < today {
+			report.UnsignedDays = append(report.UnsignedDays, day)
+		}
+	}
+
+	return report, nil
+}
+
+// mergeDays returns sorted union of sorted day lists
+func mergeDays(a []string, b []string) []string {
+	days := make([]string, 0, len(a)+len(b))
+
+	for len(a) > 0 || len(b) > 0 {
+		switch {
+		case len(b) == 0 || (len(a) > 0 && a[0] < b[0]):
+			days, a = append(days, a[0]), a[1:]
+		case len(a) == 0 || b[0] < a[0]:
+			days, b = append(days, b[0]), b[1:]
+		default:
+			days, a, b = append(days, a[0]), a[1:], b[1:]
+		}
+	}
+
+	return days
+}
+
+// missingDays lists days absent from the sorted days between the first and the last of them
+func missingDays(days []string) []string {
+	missing := make([]string, 0)
+
+	for i := 1; i < len(days); i++ {
+		previous, err := time.Parse(chainDayLayout, days[i-1])
+		if err != nil {
+			continue
+		}
+
+		for t := previous.AddDate(0, 0, 1); ChainDay(t) < days[i]; t = t.AddDate(0, 0, 1) {
+			missing = append(missing, ChainDay(t))
+		}
+	}
+
+	return missing
+}
+
+func (v *Verifier) verifyDay(ctx context.Context, day string) (int64, int, error) {
+	signed, err := v.signedHashes(ctx, day)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	check := newChainCheck(day, signed)
+
+	err = v.repository.WalkChain(ctx, day, check.next)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := check.finish(); err != nil {
+		return 0, 0, err
+	}
+
+	return check.sequence, len(signed), nil
+}
+
+// chainCheck follows events of one day in sequence order, checking each against the previous one
+// and against hashes signed by checkpoints
+type chainCheck struct {
+	day      string
+	signed   map[int64]string
+	sequence int64
+	prevHash string
+}
+
+func newChainCheck(day string, signed map[int64]string) *chainCheck {
+	return &chainCheck{day: day, signed: signed, prevHash: GenesisHash(day)}
+}
+
+func (c *chainCheck) next(event *Event) error {
+	c.sequence++
+
+	broken := &BrokenLinkError{ChainDay: c.day, Sequence: c.sequence, EventID: event.ID.Hex()}
+
+	switch {
+	case event.Sequence != c.sequence:
+		broken.Reason = fmt.Sprintf("event is missing, found sequence %d instead", event.Sequence)
+	case event.PrevHash != c.prevHash:
+		broken.Reason = "previous hash does not match the previous event"
+	case event.ComputeHash() != event.Hash:
+		broken.Reason = "event content does not match its hash"
+	case c.signed[c.sequence] != "" && c.signed[c.sequence] != event.Hash:
+		broken.Reason = "event does not match signed checkpoint"
+	default:
+		c.prevHash = event.Hash
+		return nil
+	}
+
+	return broken
+}
+
+// finish reports events removed from the end, which keeps the chain consistent so only checkpoints reveal it
+func (c *chainCheck) finish() error {
+	for checkpointSequence := range c.signed {
+		if checkpointSequence > c.sequence {
+			return &BrokenLinkError{ChainDay: c.day, Sequence: c.sequence + 1, Reason: fmt.Sprintf("events up to signed sequence %d are missing", checkpointSequence)}
+		}
+	}
+
+	return nil
+}
+
+// signedHashes verifies checkpoint signatures of the day and returns the signed hash by sequence
+func (v *Verifier) signedHashes(ctx context.Context, day string) (map[int64]string, error) {
+	checkpoints, err := v.checkpointRepository.GetByDay(ctx, day)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get audit checkpoints")
+	}
+
+	signed := map[int64]string{}
+
+	for _, checkpoint := range checkpoints {
+		claims := &CheckpointClaims{}
+
+		token, err := jwt.ParseWithClaims(checkpoint.Signature, claims, v.keyfunc)
+		if err != nil || !token.Valid {
+			return nil, &BrokenLinkError{ChainDay: day, Sequence: checkpoint.Sequence, Reason: "checkpoint signature is invalid"}
+		}
+
+		// stored fields are not trusted, only what was signed
+		if claims.ChainDay != day {
+			return nil, &BrokenLinkError{ChainDay: day, Sequence: checkpoint.Sequence, Reason: "checkpoint is signed for another day"}
+		}
+
+		signed[claims.Sequence] = claims.Hash
+	}
+
+	return signed, nil
+}
@@ -0,0 +1,202 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const testDay = "2024-01-15"
+
+// testChain builds correctly linked events of the test day
+func testChain(count int) []*Event {
+	events := make([]*Event, 0, count)
+	prevHash := GenesisHash(testDay)
+
+	for i := 1; i <= count; i++ {
+		event := &Event{
+			ID:        primitive.NewObjectID(),
+			CreatedOn: time.Date(2024, 1, 15, 10, i, 0, 0, time.UTC),
+			Action:    ActionLogin,
+			Outcome:   OutcomeSuccess,
+			ActorID:   "user-1",
+			ChainDay:  testDay,
+			Sequence:  int64(i),
+			PrevHash:  prevHash,
+		}
+		event.Hash = event.ComputeHash()
+		prevHash = event.Hash
+
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func TestChainCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   func() []*Event
+		signed   func(events []*Event) map[int64]string
+		sequence int64
+		reason   string
+	}{
+		{
+			name:   "intact chain",
+			events: func() []*Event { return testChain(3) },
+		},
+		{
+			name:   "intact chain with checkpoints",
+			events: func() []*Event { return testChain(3) },
+			signed: func(events []*Event) map[int64]string {
+				return map[int64]string{2: events[1].Hash, 3: events[2].Hash}
+			},
+		},
+		{
+			name: "tampered content",
+			events: func() []*Event {
+				events := testChain(3)
+				events[1].Outcome = OutcomeFailure
+				return events
+			},
+			sequence: 2,
+			reason:   "event content does not match its hash",
+		},
+		{
+			name: "tampered content with recomputed hash",
+			events: func() []*Event {
+				events := testChain(3)
+				events[1].Outcome = OutcomeFailure
+				events[1].Hash = events[1].ComputeHash()
+				return events
+			},
+			sequence: 3,
+			reason:   "previous hash does not match the previous event",
+		},
+		{
+			name: "missing middle event",
+			events: func() []*Event {
+				events := testChain(3)
+				return []*Event{events[0], events[2]}
+			},
+			sequence: 2,
+			reason:   "event is missing, found sequence 3 instead",
+		},
+		{
+			name: "wrong previous hash",
+			events: func() []*Event {
+				events := testChain(3)
+				events[0].PrevHash = GenesisHash("2024-01-14")
+				events[0].Hash = events[0].ComputeHash()
+				return events
+			},
+			sequence: 1,
+			reason:   "previous hash does not match the previous event",
+		},
+		{
+			name: "rewritten chain against checkpoint",
+			events: func() []*Event {
+				events := testChain(3)
+				events[0].Outcome = OutcomeFailure
+				prevHash := GenesisHash(testDay)
+				for _, event := range events {
+					event.PrevHash = prevHash
+					event.Hash = event.ComputeHash()
+					prevHash = event.Hash
+				}
+				return events
+			},
+			signed: func(events []*Event) map[int64]string {
+				return map[int64]string{2: "hash signed before the rewrite"}
+			},
+			sequence: 2,
+			reason:   "event does not match signed checkpoint",
+		},
+		{
+			name: "truncated tail beyond checkpoint",
+			events: func() []*Event {
+				return testChain(2)
+			},
+			signed: func(events []*Event) map[int64]string {
+				return map[int64]string{2: events[1].Hash, 4: "removed"}
+			},
+			sequence: 3,
+			reason:   "events up to signed sequence 4 are missing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := tt.events()
+
+			signed := map[int64]string{}
+			if tt.signed != nil {
+				signed = tt.signed(events)
+			}
+
+			check := newChainCheck(testDay, signed)
+
+			var err error
+			for _, event := range events {
+				if err = check.next(event); err != nil {
+					break
+				}
+			}
+
+			if err == nil {
+				err = check.finish()
+			}
+
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatalf("expected chain to verify, got %v", err)
+				}
+
+				if check.sequence != int64(len(events)) {
+					t.Fatalf("expected %d verified events, got %d", len(events), check.sequence)
+				}
+
+				return
+			}
+
+			var broken *BrokenLinkError
+			if !errors.As(err, &broken) {
+				t.Fatalf("expected BrokenLinkError, got %v", err)
+			}
+
+			if broken.ChainDay != testDay || broken.Sequence != tt.sequence || broken.Reason != tt.reason {
+				t.Fatalf("expected break at %d: %s, got %v", tt.sequence, tt.reason, broken)
+			}
+		})
+	}
+}
+
+func TestMissingDays(t *testing.T) {
+	tests := []struct {
+		name    string
+		days    []string
+		missing []string
+	}{
+		{name: "no days", days: nil, missing: []string{}},
+		{name: "consecutive days", days: []string{"2024-01-30", "2024-01-31", "2024-02-01"}, missing: []string{}},
+		{name: "gap", days: []string{"2024-01-30", "2024-02-02"}, missing: []string{"2024-01-31", "2024-02-01"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing := missingDays(tt.days)
+
+			if len(missing) != len(tt.missing) {
+				t.Fatalf("expected %v, got %v", tt.missing, missing)
+			}
+
+			for i := range missing {
+				if missing[i] != tt.missing[i] {
+					t.Fatalf("expected %v, got %v", tt.missing, missing)
+				}
+			}
+		})
+	}
+}
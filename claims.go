@@ -1,6 +1,33 @@
 package passport
 
-import "github.com/golang-jwt/jwt"
+import (
+	"github.com/golang-jwt/jwt"
+	"golang.org/x/exp/slices"
+)
+
+// Authentication method references recorded in amr claim
+const (
+	AmrPassword = "pwd"
+	AmrOTP      = "otp"
+	AmrWebAuthn = "webauthn"
+	AmrSocial   = "social"
+	AmrMfa      = "mfa"
+)
+
+// Authentication context class references recorded in acr claim
+const (
+	AcrSingleFactor = "aal1"
+	AcrMultiFactor  = "aal2"
+)
+
+// AuthLevel returns acr of authentication performed with the methods, passkeys count as multi-factor
+func AuthLevel(methods []string) string {
+	if slices.Contains(methods, AmrMfa) || slices.Contains(methods, AmrWebAuthn) {
+		return AcrMultiFactor
+	}
+
+	return AcrSingleFactor
+}
 
 type UserClaims struct {
 	jwt.StandardClaims
@@ -9,8 +36,48 @@ type UserClaims struct {
 	Rights     []string `json:"rights"`
 	IsAdmin    bool     `json:"isAdmin"`
 	IsVerified bool     `json:"isVerified"`
+	IsClient   bool     `json:"isClient,omitempty"`
+	Scope      string   `json:"scope,omitempty"`
+	ClientID   string   `json:"client_id,omitempty"`
+	Act        *Actor   `json:"act,omitempty"`
+	AuthTime   int64    `json:"auth_time,omitempty"`
+	Amr        []string `json:"amr,omitempty"`
+	Acr        string   `json:"acr,omitempty"`
+	// SessionID binds the token to session so signing out revokes it before it expires
+	SessionID string `json:"sid,omitempty"`
+	// TokenVersion is the user token version at issuance, password reset and privilege changes bump it
+	TokenVersion int `json:"ver,omitempty"`
+}
+
+// Actor identifies the party acting on behalf of the token subject as defined in RFC 8693
+type Actor struct {
+	Subject  string `json:"sub"`
+	ClientID string `json:"client_id,omitempty"`
+	Act      *Actor `json:"act,omitempty"`
 }
 
 func (c UserClaims) Valid() error {
 	return c.StandardClaims.Valid()
 }
+
+// IsSessionToken reports whether the token stands for the user's own login session,
+// delegated, exchanged and machine tokens and tokens meant for other audiences do not
+func (c UserClaims) IsSessionToken() bool {
+	return !c.IsClient && c.Act == nil && c.Audience == "" && c.SessionID != ""
+}
+
+type IDTokenClaims struct {
+	jwt.StandardClaims
+	Nonce             string   `json:"nonce,omitempty"`
+	AuthTime          int64    `json:"auth_time"`
+	Amr               []string `json:"amr,omitempty"`
+	Acr               string   `json:"acr,omitempty"`
+	SessionID         string   `json:"sid,omitempty"`
+	Email             string   `json:"email,omitempty"`
+	EmailVerified     bool     `json:"email_verified,omitempty"`
+	PreferredUsername string   `json:"preferred_username,omitempty"`
+}
+
+func (c IDTokenClaims) Valid() error {
+	return c.StandardClaims.Valid()
+}
@@ -0,0 +1,81 @@
+package clients
+
+import (
+	"strings"
+	"time"
+
+	"github.com/georgi-georgiev/passport/permissions"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/exp/slices"
+)
+
+type Client struct {
+	ID           primitive.ObjectID   `bson:"_id"`
+	CreatedOn    time.Time            `bson:"createdOn"`
+	UpdatedOn    *time.Time           `bson:"updatedOn,omitempty"`
+	IsActive     bool                 `bson:"isActive"`
+	IsPublic     bool                 `bson:"isPublic"`
+	ClientID     string               `bson:"clientId"`
+	SecretHash   string               `bson:"secretHash,omitempty"`
+	Name         string               `bson:"name"`
+	RedirectURIs []string             `bson:"redirectUris,omitempty"`
+	GrantTypes   []string             `bson:"grantTypes"`
+	Scopes       []string             `bson:"scopes,omitempty"`
+	Audiences    []string             `bson:"audiences,omitempty"`
+	Role         primitive.ObjectID   `bson:"role,omitempty"`
+	Rights       []primitive.ObjectID `bson:"rights,omitempty"`
+}
+
+func NewClient(clientID string, secretHash string, name string, isPublic bool, redirectURIs []string, grantTypes []string, scopes []string, audiences []string, role *permissions.Role, rights []*permissions.Right) *Client {
+
+	rightsIds := make([]primitive.ObjectID, 0)
+	for _, right := range rights {
+		rightsIds = append(rightsIds, right.ID)
+	}
+
+	roleId := primitive.NilObjectID
+	if role != nil {
+		roleId = role.ID
+	}
+
+	return &Client{
+		ID:           primitive.NewObjectID(),
+		CreatedOn:    time.Now().UTC(),
+		IsActive:     true,
+		IsPublic:     isPublic,
+		ClientID:     clientID,
+		SecretHash:   secretHash,
+		Name:         name,
+		RedirectURIs: redirectURIs,
+		GrantTypes:   grantTypes,
+		Scopes:       scopes,
+		Audiences:    audiences,
+		Role:         roleId,
+		Rights:       rightsIds,
+	}
+}
+
+// AllowsAudience reports whether tokens targeted at the audience can be requested by the client
+func (c *Client) AllowsAudience(audience string) bool {
+	return slices.Contains(c.Audiences, audience)
+}
+
+func (c *Client) AllowsGrant(grantType string) bool {
+	return slices.Contains(c.GrantTypes, grantType)
+}
+
+// AllowsRedirectURI requires exact match with one of the registered redirect uris
+func (c *Client) AllowsRedirectURI(redirectURI string) bool {
+	return slices.Contains(c.RedirectURIs, redirectURI)
+}
+
+// AllowsScope reports whether every space delimited scope is registered for the client
+func (c *Client) AllowsScope(scope string) bool {
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(c.Scopes, s) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,116 @@
+package clients
+
+import (
+	"net/http"
+
+	"github.com/georgi-georgiev/blunder"
+	"github.com/georgi-georgiev/passport/payloads"
+	"github.com/georgi-georgiev/passport/responses"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type ClientHandlers struct {
+	clientService *ClientService
+	log           *zap.Logger
+	blunder       *blunder.Blunder
+}
+
+func NewClientHandlers(clientService *ClientService, log *zap.Logger, blunder *blunder.Blunder) *ClientHandlers {
+	return &ClientHandlers{clientService: clientService, log: log, blunder: blunder}
+}
+
+// CreateClientHandler godoc
+// @Summary Create client
+// @Description register OAuth client, the secret is returned only once
+// @Tags identity
+// @Accept  json
+// @Produce  json
+// @Security OAuth2Application
+// @Param data body CreateClientPayload true "data"
+// @Success 201 {object} CreateClientResponse
+// @Router /clients [post]
+func (h *ClientHandlers) CreateClient(c *gin.Context) {
+	var payload payloads.CreateClientPayload
+	errors := h.blunder.BindJson(c.Request, &payload)
+	if errors != nil {
+		for _, err := range errors {
+			h.blunder.GinAdd(c, err)
+		}
+		return
+	}
+
+	client, secret, err := h.clientService.CreateClient(c.Request.Context(), payload)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, responses.CreateClientResponse{ClientResponse: MapToClientResponse(client), ClientSecret: secret})
+}
+
+// GetClientsHandler godoc
+// @Summary Get clients
+// @Description get clients
+// @Tags identity
+// @Accept  json
+// @Produce  json
+// @Security OAuth2Application
+// @Success 200 {array} ClientResponse
+// @Router /clients [get]
+func (h *ClientHandlers) GetClients(c *gin.Context) {
+	clients, err := h.clientService.GetClients(c.Request.Context())
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	response := []responses.ClientResponse{}
+	for _, client := range clients {
+		response = append(response, MapToClientResponse(client))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteClientHandler godoc
+// @Summary Delete client
+// @Description delete client
+// @Tags identity
+// @Accept  json
+// @Produce  json
+// @Security OAuth2Application
+// @Param clientId path string true "1"
+// @Router /clients/{clientId} [delete]
+func (h *ClientHandlers) DeleteClient(c *gin.Context) {
+	clientId := c.Param("clientId")
+	if clientId == "" {
+		c.JSON(http.StatusBadRequest, blunder.BadRequest())
+		return
+	}
+
+	isDeleted, err := h.clientService.DeleteClient(c.Request.Context(), clientId)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	if !isDeleted {
+		c.JSON(http.StatusNotFound, blunder.NotFound())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func MapToClientResponse(client *Client) responses.ClientResponse {
+	return responses.ClientResponse{
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		IsPublic:     client.IsPublic,
+		RedirectURIs: client.RedirectURIs,
+		GrantTypes:   client.GrantTypes,
+		Scopes:       client.Scopes,
+		Audiences:    client.Audiences,
+	}
+}
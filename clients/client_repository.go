@@ -0,0 +1,72 @@
+package clients
+
+import (
+	"context"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ClientRepository struct {
+	*passport.MongoRepository
+}
+
+func NewClientRepository(client *mongo.Client, conf *passport.Config) *ClientRepository {
+	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "clients")
+
+	clientIdIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "clientId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := repository.Collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{clientIdIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	return &ClientRepository{repository}
+}
+
+func (r *ClientRepository) GetByClientID(ctx context.Context, clientID string) (*Client, error) {
+	result := &Client{}
+
+	err := r.Collection.FindOne(ctx, bson.M{
+		"clientId": clientID,
+	}).Decode(result)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *ClientRepository) GetAll(ctx context.Context) ([]*Client, error) {
+	clients := []*Client{}
+
+	cur, err := r.Collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		c := &Client{}
+
+		err = cur.Decode(c)
+
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, c)
+	}
+
+	return clients, nil
+}
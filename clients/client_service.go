@@ -0,0 +1,291 @@
+package clients
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"github.com/georgi-georgiev/passport/payloads"
+	"github.com/georgi-georgiev/passport/permissions"
+	"github.com/golang-jwt/jwt"
+	"github.com/rotisserie/eris"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+	GrantDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+// GrantTypes lists grants that can be registered for a client
+var GrantTypes = []string{GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials, GrantDeviceCode, GrantTokenExchange}
+
+type ClientService struct {
+	repository   *ClientRepository
+	roleService  *permissions.RoleService
+	rightService *permissions.RightService
+	keyManager   *passport.KeyManager
+	conf         *passport.Config
+	log          *zap.Logger
+}
+
+func NewClientService(repository *ClientRepository, roleService *permissions.RoleService, rightService *permissions.RightService, keyManager *passport.KeyManager, conf *passport.Config, log *zap.Logger) *ClientService {
+	return &ClientService{repository: repository, roleService: roleService, rightService: rightService, keyManager: keyManager, conf: conf, log: log}
+}
+
+// CreateClient registers client returning its secret, which is empty for public clients
+func (s *ClientService) CreateClient(ctx context.Context, payload payloads.CreateClientPayload) (*Client, string, error) {
+	for _, grantType := range payload.GrantTypes {
+		if !slices.Contains(GrantTypes, grantType) {
+			return nil, "", eris.Errorf("Grant type %s is not supported", grantType)
+		}
+	}
+
+	if slices.Contains(payload.GrantTypes, GrantAuthorizationCode) && len(payload.RedirectURIs) == 0 {
+		return nil, "", eris.New("Redirect uri is required for authorization code grant")
+	}
+
+	if slices.Contains(payload.GrantTypes, GrantClientCredentials) && payload.IsPublic {
+		return nil, "", eris.New("Public clients can not use client credentials grant")
+	}
+
+	if slices.Contains(payload.GrantTypes, GrantTokenExchange) && payload.IsPublic {
+		return nil, "", eris.New("Public clients can not use token exchange grant")
+	}
+
+	var role *permissions.Role
+	if payload.Role != "" {
+		var err error
+		role, err = s.roleService.GetByName(ctx, payload.Role)
+		if err != nil {
+			return nil, "", eris.Wrap(err, "could not get role by name")
+		}
+
+		if role == nil {
+			return nil, "", eris.New("could not find role")
+		}
+	}
+
+	rights := make([]*permissions.Right, 0)
+	for _, rightName := range payload.Rights {
+		right, err := s.rightService.GetByName(ctx, rightName)
+		if err != nil {
+			return nil, "", eris.Wrap(err, "could not get right by name")
+		}
+
+		if right == nil {
+			return nil, "", eris.New("could not find right")
+		}
+
+		rights = append(rights, right)
+	}
+
+	clientID, err := generateClientID()
+	if err != nil {
+		return nil, "", eris.Wrap(err, "could not generate client id")
+	}
+
+	secret := ""
+	secretHash := ""
+
+	if !payload.IsPublic {
+		secret, err = generateSecret()
+		if err != nil {
+			return nil, "", eris.Wrap(err, "could not generate client secret")
+		}
+
+		secretHash, err = passport.Hash(secret)
+		if err != nil {
+			return nil, "", eris.Wrap(err, "could not hash client secret")
+		}
+	}
+
+	client := NewClient(clientID, secretHash, payload.Name, payload.IsPublic, payload.RedirectURIs, payload.GrantTypes, payload.Scopes, payload.Audiences, role, rights)
+
+	_, err = s.repository.Create(ctx, client)
+	if err != nil {
+		return nil, "", eris.Wrap(err, "could not create client")
+	}
+
+	return client, secret, nil
+}
+
+func (s *ClientService) GetByClientID(ctx context.Context, clientID string) (*Client, error) {
+	client, err := s.repository.GetByClientID(ctx, clientID)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get client by client id")
+	}
+
+	if client == nil || !client.IsActive {
+		return nil, nil
+	}
+
+	return client, nil
+}
+
+// AuthenticateClient verifies client credentials, public clients are identified by client id only
+func (s *ClientService) AuthenticateClient(ctx context.Context, clientID string, secret string) (*Client, error) {
+	if clientID == "" {
+		return nil, passport.InvalidClient("Client authentication failed")
+	}
+
+	client, err := s.GetByClientID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		return nil, passport.InvalidClient("Client authentication failed")
+	}
+
+	if client.IsPublic {
+		if secret != "" {
+			return nil, passport.InvalidClient("Public client must not authenticate with secret")
+		}
+
+		return client, nil
+	}
+
+	if secret == "" || !passport.Match(secret, client.SecretHash) {
+		return nil, passport.InvalidClient("Client authentication failed")
+	}
+
+	return client, nil
+}
+
+// ClientCredentialsToken issues access token whose subject is the client and whose rights come from the client permissions
+func (s *ClientService) ClientCredentialsToken(ctx context.Context, client *Client, scope string) (string, int64, string, error) {
+	if !client.AllowsGrant(GrantClientCredentials) || client.IsPublic {
+		return "", 0, "", passport.UnauthorizedClient("Client is not allowed to use client credentials grant")
+	}
+
+	if scope == "" {
+		scope = strings.Join(client.Scopes, " ")
+	}
+
+	if !client.AllowsScope(scope) {
+		return "", 0, "", passport.InvalidScope("Requested scope is not allowed for the client")
+	}
+
+	claims, err := s.MapToClientClaims(ctx, client, scope)
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	token, err := s.keyManager.SignAccessToken(claims)
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	return token, claims.ExpiresAt, scope, nil
+}
+
+// LoadPermissions returns the role and the rights assigned to the client
+func (s *ClientService) LoadPermissions(ctx context.Context, client *Client) (*permissions.Role, []permissions.Right, error) {
+	var role *permissions.Role
+	var err error
+
+	if !client.Role.IsZero() {
+		role, err = s.roleService.GetById(ctx, client.Role)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	rights := make([]permissions.Right, 0)
+
+	if len(client.Rights) > 0 {
+		rights, err = s.rightService.GetManyByIds(ctx, client.Rights)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return role, rights, nil
+}
+
+func (s *ClientService) MapToClientClaims(ctx context.Context, client *Client, scope string) (*passport.UserClaims, error) {
+	role, _, err := s.LoadPermissions(ctx, client)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not load client permissions")
+	}
+
+	rightsIds := make([]string, 0)
+	for _, right := range client.Rights {
+		rightsIds = append(rightsIds, right.Hex())
+	}
+
+	now := time.Now().UTC()
+
+	claims := &passport.UserClaims{
+		Rights:   rightsIds,
+		IsClient: true,
+		Scope:    scope,
+		ClientID: client.ClientID,
+		StandardClaims: jwt.StandardClaims{
+			Id:        primitive.NewObjectID().Hex(),
+			Issuer:    s.conf.Token.Issuer,
+			Subject:   client.ClientID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(s.conf.Token.AccessTokenTTL).Unix(),
+		},
+	}
+
+	if role != nil {
+		claims.RoleId = role.ID.Hex()
+		claims.Role = role.Name
+	}
+
+	return claims, nil
+}
+
+func (s *ClientService) GetClients(ctx context.Context) ([]*Client, error) {
+	clients, err := s.repository.GetAll(ctx)
+	if err != nil {
+		return nil, eris.Wrap(err, "could no get all clients")
+	}
+
+	return clients, nil
+}
+
+func (s *ClientService) DeleteClient(ctx context.Context, clientID string) (bool, error) {
+	client, err := s.repository.GetByClientID(ctx, clientID)
+	if err != nil {
+		return false, err
+	}
+
+	if client == nil {
+		return false, nil
+	}
+
+	return s.repository.DeleteById(ctx, client.ID)
+}
+
+func generateClientID() (string, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"github.com/georgi-georgiev/passport/audit"
+	"github.com/georgi-georgiev/passport/pkg/breach"
+)
+
+// runCommand runs maintenance subcommand instead of the server, returning process exit code
+func runCommand(args []string) int {
+	switch args[0] {
+	case "breach":
+		return runBreach(args[1:])
+	case "audit":
+		return runAudit(args[1:])
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %s\n", args[0])
+	return 2
+}
+
+func runBreach(args []string) int {
+	if len(args) == 0 || args[0] != "build" {
+		fmt.Fprintln(os.Stderr, "usage: passport breach build -in pwned-passwords-sha1.txt -out breached.filter")
+		return 2
+	}
+
+	flags := flag.NewFlagSet("breach build", flag.ContinueOnError)
+	in := flags.String("in", "", "HIBP SHA-1 hash list with HASH:COUNT lines")
+	out := flags.String("out", "breached.filter", "filter file to write")
+	rate := flags.Float64("rate", 0.001, "false positive rate")
+
+	err := flags.Parse(args[1:])
+	if err != nil {
+		return 2
+	}
+
+	if *in == "" || *rate <= 0 || *rate >= 1 {
+		flags.Usage()
+		return 2
+	}
+
+	err = buildBreachFilter(*in, *out, *rate)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func buildBreachFilter(in string, out string, rate float64) error {
+	// first pass only counts hashes so the filter can be sized before it is filled
+	expected, err := countLines(in)
+	if err != nil {
+		return err
+	}
+
+	input, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	filter, err := breach.BuildFilter(bufio.NewReaderSize(input, 1<<20), expected, rate)
+	if err != nil {
+		return err
+	}
+
+	output, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(output)
+
+	_, err = filter.WriteTo(writer)
+	if err != nil {
+		output.Close()
+		return err
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		output.Close()
+		return err
+	}
+
+	err = output.Close()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote filter of %d hashes to %s\n", expected, out)
+	return nil
+}
+
+func countLines(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var count uint64
+
+	scanner := bufio.NewScanner(bufio.NewReaderSize(file, 1<<20))
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			count++
+		}
+	}
+
+	return count, scanner.Err()
+}
+
+func runAudit(args []string) int {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, "usage: passport audit verify -jwks keys.json [-from 2006-01-02] [-to 2006-01-02]")
+		return 2
+	}
+
+	flags := flag.NewFlagSet("audit verify", flag.ContinueOnError)
+	from := flags.String("from", "", "first day to verify, the oldest day when empty")
+	to := flags.String("to", "", "last day to verify, today when empty")
+	jwks := flags.String("jwks", "", "JSON Web Key Set with every key that signed checkpoints, kept outside the database")
+
+	err := flags.Parse(args[1:])
+	if err != nil {
+		return 2
+	}
+
+	for _, day := range []string{*from, *to} {
+		if _, err := time.Parse("2006-01-02", day); day != "" && err != nil {
+			flags.Usage()
+			return 2
+		}
+	}
+
+	if *jwks == "" {
+		flags.Usage()
+		return 2
+	}
+
+	// checkpoints are verified with keys pinned outside the database, keys read from it could be replaced together with the events
+	keys, err := passport.LoadPinnedKeys(*jwks)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	conf := passport.NewConfig()
+	client := passport.NewMongoClient(conf)
+	defer client.Disconnect(context.Background())
+
+	verifier := audit.NewVerifier(audit.NewAuditRepository(client, conf), audit.NewCheckpointRepository(client, conf), keys.Keyfunc)
+
+	report, err := verifier.Verify(context.Background(), *from, *to)
+
+	var brokenLink *audit.BrokenLinkError
+	if errors.As(err, &brokenLink) {
+		fmt.Printf("audit log is tampered: %s\n", brokenLink)
+		return 1
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("audit log is intact: %d events in %d days, %d checkpoints\n", report.Events, report.Days, report.Checkpoints)
+
+	for _, day := range report.UnsignedDays {
+		fmt.Printf("warning: %s has no signed checkpoint, removal of its last events can not be detected\n", day)
+	}
+
+	for _, day := range report.EmptyDays {
+		fmt.Printf("warning: %s has no events and no checkpoints, it could have been removed as a whole\n", day)
+	}
+
+	return 0
+}
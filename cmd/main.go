@@ -3,9 +3,12 @@ package main
 import (
 	"context"
 	"net/http"
+	"os"
 
 	"github.com/georgi-georgiev/blunder"
 	"github.com/georgi-georgiev/passport"
+	"github.com/georgi-georgiev/passport/audit"
+	"github.com/georgi-georgiev/passport/clients"
 	"github.com/georgi-georgiev/passport/facade"
 	"github.com/georgi-georgiev/passport/notifications"
 	"github.com/georgi-georgiev/passport/permissions"
@@ -29,6 +32,10 @@ import (
 // @tokenUrl http://localhost:3535/token
 // @authorizationurl http://localhost:3535/token
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	fx.New(
 		fx.Provide(
 			passport.NewHTTPServer,
@@ -40,6 +47,14 @@ func main() {
 			passport.NewGinEngine,
 			passport.NewMongoClient,
 			passport.NewMailCleint,
+			passport.NewKeyManager,
+			passport.NewPasswordPolicy,
+			passport.NewPasswordHasher,
+
+			audit.NewAuditRepository,
+			audit.NewCheckpointRepository,
+			audit.NewAuditService,
+			audit.NewAuditHandlers,
 
 			notifications.NewNotificationRepository,
 			facade.NewNotificationFacade,
@@ -52,11 +67,34 @@ func main() {
 			permissions.NewRoleService,
 			permissions.NewPermissionHandlers,
 
+			clients.NewClientRepository,
+			clients.NewClientService,
+			clients.NewClientHandlers,
+
 			users.NewUserRepository,
+			users.NewRefreshTokenRepository,
+			users.NewRevokedTokenRepository,
+			users.NewAuthorizationCodeRepository,
+			users.NewDeviceCodeRepository,
+			users.NewMfaChallengeRepository,
+			users.NewLoginAttemptRepository,
+			users.NewLockoutService,
+			users.NewSessionRepository,
+			users.NewSessionService,
+			users.NewKnownDeviceRepository,
+			users.NewRiskService,
+			users.NewMfaService,
+			users.NewWebAuthnCredentialRepository,
+			users.NewWebAuthnChallengeRepository,
+			users.NewWebAuthnService,
+			users.NewLoginCodeRepository,
+			users.NewPasswordlessService,
 			users.NewUserService,
+			users.NewOAuthService,
 			users.NewUserHandlers,
 
 			middlewares.NewMiddleware,
+			middlewares.NewRateLimiter,
 		),
 		fx.WithLogger(func(logger *zap.Logger) fxevent.Logger {
 			return &fxevent.ZapLogger{Logger: logger}
@@ -65,6 +103,12 @@ func main() {
 		fx.Invoke(func(notifcationService *notifications.NotificationService) {
 			notifcationService.Listener()
 		}),
+		fx.Invoke(func(keyManager *passport.KeyManager) {
+			keyManager.Scheduler()
+		}),
+		fx.Invoke(func(auditService *audit.AuditService) {
+			auditService.Scheduler()
+		}),
 		fx.Invoke(router.Router),
 	).Run()
 }
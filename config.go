@@ -1,6 +1,7 @@
 package passport
 
 import (
+	"fmt"
 	"os"
 	"time"
 
@@ -9,12 +10,23 @@ import (
 )
 
 type Config struct {
-	Server  ServerConfiguration
-	Mongo   MongoConfiguration
-	Sentry  SentryConfiguration
-	Mail    MailConfiguration
-	App     AppConfiguration
-	Swagger SwaggerConfiguration
+	Server          ServerConfiguration
+	Mongo           MongoConfiguration
+	Sentry          SentryConfiguration
+	Mail            MailConfiguration
+	App             AppConfiguration
+	Token           TokenConfiguration
+	Keys            KeysConfiguration
+	Mfa             MfaConfiguration
+	WebAuthn        WebAuthnConfiguration
+	Passwordless    PasswordlessConfiguration
+	Lockout         LockoutConfiguration
+	RateLimit       RateLimitConfiguration
+	PasswordPolicy  PasswordPolicyConfiguration
+	PasswordHashing PasswordHashingConfiguration
+	Audit           AuditConfiguration
+	Risk            RiskConfiguration
+	Swagger         SwaggerConfiguration
 }
 
 type ServerConfiguration struct {
@@ -28,12 +40,132 @@ type ServerConfiguration struct {
 		Write  time.Duration
 		Idle   time.Duration
 	}
+	// TrustedProxies lists addresses allowed to set client address with X-Forwarded-For, none by default
+	TrustedProxies []string
 }
 
 type SwaggerConfiguration struct {
 	Host string
 }
 
+type TokenConfiguration struct {
+	Issuer               string
+	AccessTokenTTL       time.Duration
+	RefreshTokenTTL      time.Duration
+	AuthorizationCodeTTL time.Duration
+	DeviceCodeTTL        time.Duration
+	DeviceCodeInterval   time.Duration
+	ImpersonationRight   string
+}
+
+type KeysConfiguration struct {
+	RotationInterval time.Duration
+	RetentionPeriod  time.Duration
+	RefreshInterval  time.Duration
+	// MinReloadInterval limits reloads triggered by tokens signed with unknown kid
+	MinReloadInterval time.Duration
+	// EncryptionKey encrypts signing private keys at rest, loaded from SIGNING_KEY_ENCRYPTION_KEY
+	EncryptionKey string
+}
+
+type MfaConfiguration struct {
+	// EncryptionKey encrypts totp secrets at rest, loaded from MFA_ENCRYPTION_KEY
+	EncryptionKey string
+	ChallengeTTL  time.Duration
+	MaxAttempts   int
+	TotpSkew      int
+	RecoveryCodes int
+	StepUpMaxAge  time.Duration
+}
+
+type WebAuthnConfiguration struct {
+	RPID                    string
+	RPName                  string
+	Origins                 []string
+	ChallengeTTL            time.Duration
+	RequireUserVerification bool
+}
+
+type PasswordlessConfiguration struct {
+	CodeTTL      time.Duration
+	MaxAttempts  int
+	MagicLinkURL string
+}
+
+// LockoutConfiguration limits password guessing, failures are counted per account and per client address
+type LockoutConfiguration struct {
+	MaxAccountFailures int
+	MaxIPFailures      int
+	Window             time.Duration
+	Duration           time.Duration
+	BaseDelay          time.Duration
+	MaxDelay           time.Duration
+}
+
+// RateLimitConfiguration throttles unauthenticated endpoints, backend is memory for single instance or mongo for replicas
+type RateLimitConfiguration struct {
+	Backend  string
+	Signup   RateLimitRule
+	Token    RateLimitRule
+	Login    RateLimitRule
+	Recovery RateLimitRule
+	Mailbox  RateLimitRule
+	Verify   RateLimitRule
+}
+
+// RateLimitRule allows requests per period refilled evenly, with bursts up to burst requests
+type RateLimitRule struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+type PasswordPolicyConfiguration struct {
+	MinLength         int
+	MaxLength         int
+	RequireUpper      bool
+	RequireLower      bool
+	RequireDigit      bool
+	RequireSymbol     bool
+	ForbidIdentifiers bool
+	Dictionary        bool
+	// BreachedPath is HIBP range directory or filter built with passport breach build, empty disables the check
+	BreachedPath string
+	// WarnBreached logs breached passwords instead of rejecting them
+	WarnBreached bool
+}
+
+// PasswordHashingConfiguration selects algorithm for new password hashes, memory is in KiB
+type PasswordHashingConfiguration struct {
+	Algorithm   string
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+	BcryptCost  int
+}
+
+// AuditConfiguration sets how often heads of the audit chains are signed, zero disables checkpoints
+type AuditConfiguration struct {
+	CheckpointInterval time.Duration
+}
+
+// RiskConfiguration scores logins from unfamiliar devices and places. GeoIPPath is DB-IP lite csv, empty disables
+// country and travel signals. MaxTravelSpeed is in km/h. Alert email is sent from AlertScore and email one-time code
+// is required from ChallengeScore, zero disables either.
+type RiskConfiguration struct {
+	GeoIPPath             string
+	NewDeviceScore        int
+	NewIPRangeScore       int
+	NewCountryScore       int
+	ImpossibleTravelScore int
+	MaxTravelSpeed        float64
+	AlertScore            int
+	ChallengeScore        int
+	DeviceTTL             time.Duration
+}
+
 type MongoConfiguration struct {
 	Url      string
 	Dbname   string
@@ -52,10 +184,10 @@ type MailConfiguration struct {
 }
 
 type AppConfiguration struct {
-	Name        string
-	Version     string
-	PrivKeyPath string
-	PubKeyPath  string
+	Name             string
+	Version          string
+	PrivKeyPath      string
+	SigningAlgorithm string
 }
 
 func NewConfig() *Config {
@@ -80,5 +212,20 @@ func NewConfig() *Config {
 		panic(err)
 	}
 
+	// encryption keys are secrets and are never read from the committed config file
+	config.Keys.EncryptionKey = os.Getenv("SIGNING_KEY_ENCRYPTION_KEY")
+
+	_, err = newAEAD(config.Keys.EncryptionKey)
+	if err != nil {
+		panic(fmt.Sprintf("SIGNING_KEY_ENCRYPTION_KEY has to be set to base64 encoded 32 byte key: %v", err))
+	}
+
+	config.Mfa.EncryptionKey = os.Getenv("MFA_ENCRYPTION_KEY")
+
+	_, err = newAEAD(config.Mfa.EncryptionKey)
+	if err != nil {
+		panic(fmt.Sprintf("MFA_ENCRYPTION_KEY has to be set to base64 encoded 32 byte key: %v", err))
+	}
+
 	return config
 }
@@ -1,10 +1,18 @@
 package passport
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
-// Hash salts password and hashes it, returning salted hash
+// Hash salts and hashes short lived codes and client secrets, user passwords are hashed with PasswordHasher
 func Hash(password string) (string, error) {
 	hash, er := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
 	if er != nil {
@@ -19,3 +27,70 @@ func Match(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword(byteHash, []byte(password))
 	return err == nil
 }
+
+// HashToken hashes high-entropy opaque tokens so they can be stored and looked up by value
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Encrypt seals plaintext with AES-GCM using base64 encoded 256 bit key, nonce is prepended to the result.
+// Additional data is authenticated but not stored, the same has to be given to Decrypt.
+func Encrypt(encodedKey string, plaintext string, additionalData []byte) (string, error) {
+	aead, err := newAEAD(encodedKey)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), additionalData)
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens value produced by Encrypt
+func Decrypt(encodedKey string, ciphertext string, additionalData []byte) (string, error) {
+	aead, err := newAEAD(encodedKey)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("ciphertext is too short")
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+func newAEAD(encodedKey string) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != 32 {
+		return nil, errors.New("encryption key must be 32 bytes")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
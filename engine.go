@@ -16,8 +16,15 @@ import (
 func NewGinEngine(conf *Config, logger *zap.Logger, blunder *blunder.Blunder, sentryClient *sentry.Client) *gin.Engine {
 	engine := gin.New()
 
+	// client address decides rate limits and lockout, it is taken from forwarded headers only when set by a known proxy
+	err := engine.SetTrustedProxies(conf.Server.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+
 	engine.Use(gin.CustomRecovery(blunder.GinRecovery))
 	engine.Use(blunder.GinErrorHandler(logger))
+	engine.Use(RequestInfoMiddleware())
 	engine.HandleMethodNotAllowed = true
 	engine.NoMethod(blunder.GinNoMethod)
 	engine.NoRoute(blunder.GinNoRoute)
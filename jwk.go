@@ -0,0 +1,193 @@
+package passport
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+
+	"github.com/georgi-georgiev/passport/responses"
+	"github.com/rotisserie/eris"
+)
+
+// VerificationKey is a public key that can verify tokens carrying its kid
+type VerificationKey struct {
+	Kid       string
+	Algorithm string
+	PublicKey crypto.PublicKey
+}
+
+// JWK encodes the key as RFC 7517 JSON Web Key
+func (k *VerificationKey) JWK() (responses.JSONWebKey, error) {
+	jwk := responses.JSONWebKey{
+		Kid: k.Kid,
+		Use: "sig",
+		Alg: k.Algorithm,
+	}
+
+	switch key := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = key.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(key)
+	default:
+		return responses.JSONWebKey{}, eris.New("unsupported public key type")
+	}
+
+	return jwk, nil
+}
+
+// ParseJWK decodes public key from RFC 7517 JSON Web Key
+func ParseJWK(jwk responses.JSONWebKey) (*VerificationKey, error) {
+	key := &VerificationKey{Kid: jwk.Kid, Algorithm: jwk.Alg}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, eris.Wrap(err, "could not decode rsa modulus")
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, eris.Wrap(err, "could not decode rsa exponent")
+		}
+
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, eris.New("rsa exponent is too large")
+		}
+
+		key.PublicKey = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+	case "EC":
+		if jwk.Crv != elliptic.P256().Params().Name {
+			return nil, eris.Errorf("unsupported curve %s", jwk.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, eris.Wrap(err, "could not decode ec x coordinate")
+		}
+
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, eris.Wrap(err, "could not decode ec y coordinate")
+		}
+
+		publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, eris.New("ec point is not on the curve")
+		}
+
+		key.PublicKey = publicKey
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, eris.Wrap(err, "could not decode ed25519 key")
+		}
+
+		if jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, eris.Errorf("unsupported curve %s", jwk.Crv)
+		}
+
+		key.PublicKey = ed25519.PublicKey(x)
+	default:
+		return nil, eris.Errorf("unsupported key type %s", jwk.Kty)
+	}
+
+	return key, nil
+}
+
+// Thumbprint computes RFC 7638 JWK thumbprint used as key id
+func Thumbprint(publicKey crypto.PublicKey) (string, error) {
+	jwk, err := (&VerificationKey{PublicKey: publicKey}).JWK()
+	if err != nil {
+		return "", err
+	}
+
+	// required members only, in lexicographic order
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// ParsePrivateKeyPEM parses PKCS #1, PKCS #8 or SEC 1 encoded private key
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, eris.New("could not decode private key pem")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, eris.New("unsupported private key type")
+	}
+
+	return signer, nil
+}
+
+// EncodePrivateKeyPEM encodes private key as PKCS #8 pem
+func EncodePrivateKeyPEM(key crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
@@ -0,0 +1,476 @@
+package passport
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/georgi-georgiev/passport/responses"
+	"github.com/golang-jwt/jwt"
+	"github.com/rotisserie/eris"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+// SigningAlgorithms lists supported token signing algorithms
+var SigningAlgorithms = []string{"RS256", "PS256", "ES256", "EdDSA"}
+
+type managedKey struct {
+	*SigningKey
+	signer crypto.Signer
+	method jwt.SigningMethod
+}
+
+// KeyManager holds the active signing key and the retired keys that still verify issued tokens
+type KeyManager struct {
+	repository *MongoRepository
+	conf       *Config
+	log        *zap.Logger
+	mu         sync.RWMutex
+	active     *managedKey
+	keys       map[string]*managedKey
+	reloadedOn time.Time
+}
+
+func NewKeyManager(client *mongo.Client, conf *Config, log *zap.Logger) *KeyManager {
+	repository := NewMongoRepository(client, conf.Mongo.Dbname, "signing_keys")
+
+	kidIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "kid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := repository.Collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{kidIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	m := &KeyManager{repository: repository, conf: conf, log: log, keys: map[string]*managedKey{}}
+
+	if !slices.Contains(SigningAlgorithms, m.Algorithm()) {
+		panic(fmt.Sprintf("unsupported signing algorithm %s", m.Algorithm()))
+	}
+
+	err = m.seed(context.TODO())
+	if err != nil {
+		panic(err)
+	}
+
+	err = m.Reload(context.TODO())
+	if err != nil {
+		panic(err)
+	}
+
+	// configured algorithm has changed, start signing with a matching key right away
+	if m.active.Algorithm != m.Algorithm() {
+		err = m.Rotate(context.TODO())
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return m
+}
+
+// Algorithm returns the configured signing algorithm
+func (m *KeyManager) Algorithm() string {
+	if m.conf.App.SigningAlgorithm == "" {
+		return "RS256"
+	}
+
+	return m.conf.App.SigningAlgorithm
+}
+
+// seed stores the configured private key when there is no signing key yet
+func (m *KeyManager) seed(ctx context.Context) error {
+	count, err := m.repository.Collection.CountDocuments(ctx, bson.M{}, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	if m.conf.App.PrivKeyPath == "" {
+		return m.create(ctx)
+	}
+
+	keyData, err := os.ReadFile(m.conf.App.PrivKeyPath)
+	if err != nil {
+		return err
+	}
+
+	signer, err := ParsePrivateKeyPEM(keyData)
+	if err != nil {
+		return err
+	}
+
+	if !isAlgorithmKey(m.Algorithm(), signer) {
+		m.log.Warn("configured private key does not match signing algorithm, generating a new one", zap.String("algorithm", m.Algorithm()))
+		return m.create(ctx)
+	}
+
+	return m.store(ctx, signer)
+}
+
+func (m *KeyManager) create(ctx context.Context) error {
+	var signer crypto.Signer
+	var err error
+
+	switch m.Algorithm() {
+	case "ES256":
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+
+	if err != nil {
+		return eris.Wrap(err, "could not generate signing key")
+	}
+
+	return m.store(ctx, signer)
+}
+
+func isAlgorithmKey(algorithm string, signer crypto.Signer) bool {
+	switch key := signer.(type) {
+	case *rsa.PrivateKey:
+		return algorithm == "RS256" || algorithm == "PS256"
+	case *ecdsa.PrivateKey:
+		return algorithm == "ES256" && key.Curve == elliptic.P256()
+	case ed25519.PrivateKey:
+		return algorithm == "EdDSA"
+	}
+
+	return false
+}
+
+func (m *KeyManager) store(ctx context.Context, signer crypto.Signer) error {
+	kid, err := Thumbprint(signer.Public())
+	if err != nil {
+		return err
+	}
+
+	keyData, err := EncodePrivateKeyPEM(signer)
+	if err != nil {
+		return err
+	}
+
+	// kid is bound to the ciphertext so encrypted key cannot be moved onto another key record
+	privateKey, err := Encrypt(m.conf.Keys.EncryptionKey, keyData, []byte(kid))
+	if err != nil {
+		return eris.Wrap(err, "could not encrypt signing key")
+	}
+
+	signingKey := NewSigningKey(kid, m.Algorithm(), privateKey)
+
+	filter := bson.M{"kid": signingKey.Kid}
+	update := bson.M{"$setOnInsert": signingKey}
+
+	opts := options.Update().SetUpsert(true)
+	_, err = m.repository.Collection.UpdateOne(ctx, filter, update, opts)
+	return err
+}
+
+// Reload loads the active key and the retired keys that are still within the retention period
+func (m *KeyManager) Reload(ctx context.Context) error {
+	retainedSince := time.Now().UTC().Add(-m.conf.Keys.RetentionPeriod)
+
+	filter := bson.M{"$or": []bson.M{
+		{"retiredOn": bson.M{"$exists": false}},
+		{"retiredOn": bson.M{"$gt": retainedSince}},
+	}}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdOn", Value: -1}})
+	cur, err := m.repository.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+
+	defer cur.Close(ctx)
+
+	var active *managedKey
+	keys := map[string]*managedKey{}
+
+	for cur.Next(ctx) {
+		signingKey := &SigningKey{}
+
+		err = cur.Decode(signingKey)
+		if err != nil {
+			return err
+		}
+
+		signer, err := parseSigningKey(m.conf.Keys.EncryptionKey, signingKey)
+		if err != nil {
+			return err
+		}
+
+		key := &managedKey{SigningKey: signingKey, signer: signer, method: jwt.GetSigningMethod(signingKey.Algorithm)}
+		if key.method == nil {
+			return eris.Errorf("unsupported signing algorithm %s", signingKey.Algorithm)
+		}
+
+		keys[key.Kid] = key
+
+		// keys are sorted newest first, fall back to the newest one while a rotation is in flight
+		if active == nil || (active.RetiredOn != nil && key.RetiredOn == nil) {
+			active = key
+		}
+	}
+
+	if active == nil {
+		return eris.New("no signing key available")
+	}
+
+	m.mu.Lock()
+	m.active = active
+	m.keys = keys
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Rotate retires the active key and replaces it with a newly generated one
+func (m *KeyManager) Rotate(ctx context.Context) error {
+	m.mu.RLock()
+	active := m.active
+	m.mu.RUnlock()
+
+	filter := bson.M{"_id": active.ID, "retiredOn": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"retiredOn": time.Now().UTC()}}
+
+	ur, err := m.repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	// another replica already rotated the key
+	if ur.ModifiedCount == 0 {
+		return m.Reload(ctx)
+	}
+
+	err = m.create(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.log.Info("signing key rotated", zap.String("retiredKid", active.Kid))
+
+	return m.Reload(ctx)
+}
+
+// Scheduler periodically reloads keys and rotates the active key once it is older than the rotation interval
+func (m *KeyManager) Scheduler() {
+	if m.conf.Keys.RefreshInterval == 0 {
+		return
+	}
+
+	go func() {
+		for {
+			time.Sleep(m.conf.Keys.RefreshInterval)
+
+			err := m.Reload(context.Background())
+			if err != nil {
+				m.log.With(zap.Error(err)).Error("could not reload signing keys")
+				continue
+			}
+
+			if m.conf.Keys.RotationInterval == 0 {
+				continue
+			}
+
+			m.mu.RLock()
+			rotateOn := m.active.CreatedOn.Add(m.conf.Keys.RotationInterval)
+			m.mu.RUnlock()
+
+			if time.Now().UTC().Before(rotateOn) {
+				continue
+			}
+
+			err = m.Rotate(context.Background())
+			if err != nil {
+				m.log.With(zap.Error(err)).Error("could not rotate signing key")
+			}
+		}
+	}()
+}
+
+// AccessTokenType is typ header of access tokens as defined in RFC 9068, id tokens and other jwts signed
+// with the same key keep the default typ so they cannot be used as access tokens
+const AccessTokenType = "at+jwt"
+
+// Sign signs the claims with the active key, stamping its kid into the header
+func (m *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	return m.sign(claims, "JWT")
+}
+
+// SignAccessToken signs access token claims, typed as at+jwt
+func (m *KeyManager) SignAccessToken(claims jwt.Claims) (string, error) {
+	return m.sign(claims, AccessTokenType)
+}
+
+func (m *KeyManager) sign(claims jwt.Claims, typ string) (string, error) {
+	m.mu.RLock()
+	key := m.active
+	m.mu.RUnlock()
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.Kid
+	token.Header["typ"] = typ
+
+	return token.SignedString(key.signer)
+}
+
+// Keyfunc selects the verification key by the kid in the token header
+func (m *KeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, found := m.getKey(kid)
+	if !found && m.reserveReload() {
+		// the key may have been rotated by another replica
+		err := m.Reload(context.Background())
+		if err != nil {
+			return nil, err
+		}
+
+		key, found = m.getKey(kid)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("unknown key id: %v", token.Header["kid"])
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.signer.Public(), nil
+}
+
+// reserveReload allows one reload per min reload interval, so tokens with made up kid cannot query the database on every request
+func (m *KeyManager) reserveReload() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.reloadedOn) < m.conf.Keys.MinReloadInterval {
+		return false
+	}
+
+	m.reloadedOn = now
+	return true
+}
+
+func (m *KeyManager) getKey(kid string) (*managedKey, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, found := m.keys[kid]
+	return key, found
+}
+
+// VerificationKeys returns public keys of the active and the retained retired keys
+func (m *KeyManager) VerificationKeys() []*VerificationKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]*VerificationKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		keys = append(keys, &VerificationKey{Kid: key.Kid, Algorithm: key.Algorithm, PublicKey: key.signer.Public()})
+	}
+
+	return keys
+}
+
+// PinnedKeys verifies long lived signatures such as audit checkpoints with public keys kept outside the database,
+// so whoever can write to the database still cannot sign. The set has to keep retired keys as well.
+type PinnedKeys struct {
+	keys map[string]*VerificationKey
+}
+
+// LoadPinnedKeys reads JSON Web Key Set, such as copies of the jwks endpoint saved after key rotations
+func LoadPinnedKeys(path string) (*PinnedKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := responses.Jwks{}
+
+	err = json.Unmarshal(data, &jwks)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not decode key set")
+	}
+
+	pinned := &PinnedKeys{keys: map[string]*VerificationKey{}}
+
+	for _, jwk := range jwks.Keys {
+		key, err := ParseJWK(jwk)
+		if err != nil {
+			return nil, eris.Wrapf(err, "could not parse key %s", jwk.Kid)
+		}
+
+		// kid is the thumbprint of the key, a key listed under another kid is not trusted
+		thumbprint, err := Thumbprint(key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if thumbprint != key.Kid {
+			return nil, eris.Errorf("key %s does not match its thumbprint", key.Kid)
+		}
+
+		pinned.keys[key.Kid] = key
+	}
+
+	if len(pinned.keys) == 0 {
+		return nil, eris.New("key set is empty")
+	}
+
+	return pinned, nil
+}
+
+// Keyfunc selects the verification key by the kid in the token header
+func (k *PinnedKeys) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, found := k.keys[kid]
+	if !found {
+		return nil, fmt.Errorf("unknown key id: %v", token.Header["kid"])
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.PublicKey, nil
+}
+
+// parseSigningKey decrypts stored private key
+func parseSigningKey(encryptionKey string, signingKey *SigningKey) (crypto.Signer, error) {
+	keyData, err := Decrypt(encryptionKey, signingKey.PrivateKey, []byte(signingKey.Kid))
+	if err != nil {
+		return nil, eris.Wrapf(err, "could not decrypt signing key %s", signingKey.Kid)
+	}
+
+	signer, err := ParsePrivateKeyPEM([]byte(keyData))
+	if err != nil {
+		return nil, eris.Wrapf(err, "could not parse signing key %s", signingKey.Kid)
+	}
+
+	return signer, nil
+}
@@ -0,0 +1,38 @@
+package passport
+
+import "net/http"
+
+// OAuthError is an error response of the OAuth 2.0 endpoints as described in RFC 6749
+type OAuthError struct {
+	Status      int
+	Code        string
+	Description string
+}
+
+func NewOAuthError(status int, code string, description string) *OAuthError {
+	return &OAuthError{Status: status, Code: code, Description: description}
+}
+
+func InvalidRequest(description string) *OAuthError {
+	return NewOAuthError(http.StatusBadRequest, "invalid_request", description)
+}
+
+func InvalidClient(description string) *OAuthError {
+	return NewOAuthError(http.StatusUnauthorized, "invalid_client", description)
+}
+
+func InvalidGrant(description string) *OAuthError {
+	return NewOAuthError(http.StatusBadRequest, "invalid_grant", description)
+}
+
+func UnauthorizedClient(description string) *OAuthError {
+	return NewOAuthError(http.StatusBadRequest, "unauthorized_client", description)
+}
+
+func InvalidScope(description string) *OAuthError {
+	return NewOAuthError(http.StatusBadRequest, "invalid_scope", description)
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
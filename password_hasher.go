@@ -0,0 +1,175 @@
+package passport
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/rotisserie/eris"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	HashAlgorithmArgon2id = "argon2id"
+	HashAlgorithmBcrypt   = "bcrypt"
+)
+
+// PasswordHasher hashes user passwords into PHC strings with configured algorithm, verifying hashes of any supported algorithm
+// so the configuration can change without invalidating stored passwords
+type PasswordHasher struct {
+	conf PasswordHashingConfiguration
+}
+
+func NewPasswordHasher(conf *Config) *PasswordHasher {
+	switch conf.PasswordHashing.Algorithm {
+	case HashAlgorithmArgon2id:
+		if conf.PasswordHashing.Memory == 0 || conf.PasswordHashing.Iterations == 0 || conf.PasswordHashing.Parallelism == 0 {
+			panic("argon2id memory, iterations and parallelism must be set")
+		}
+	case HashAlgorithmBcrypt:
+		if conf.PasswordHashing.BcryptCost < bcrypt.MinCost || conf.PasswordHashing.BcryptCost > bcrypt.MaxCost {
+			panic("bcrypt cost is out of range")
+		}
+	default:
+		panic("unknown password hashing algorithm " + conf.PasswordHashing.Algorithm)
+	}
+
+	return &PasswordHasher{conf: conf.PasswordHashing}
+}
+
+// Hash hashes password with configured algorithm and parameters
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.conf.Algorithm == HashAlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.conf.BcryptCost)
+		if err != nil {
+			return "", eris.Wrap(err, "could not hash password")
+		}
+
+		return string(hash), nil
+	}
+
+	salt := make([]byte, h.saltLength())
+
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", eris.Wrap(err, "could not generate salt")
+	}
+
+	params := argon2Params{memory: h.conf.Memory, iterations: h.conf.Iterations, parallelism: h.conf.Parallelism}
+	key := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, h.keyLength())
+
+	return encodeArgon2id(params, salt, key), nil
+}
+
+// Verify compares password with hash of any supported algorithm
+func (h *PasswordHasher) Verify(password string, hash string) bool {
+	if isBcrypt(hash) {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(computed, key) == 1
+}
+
+// NeedsRehash reports whether hash was made with other algorithm or parameters than currently configured
+func (h *PasswordHasher) NeedsRehash(hash string) bool {
+	if isBcrypt(hash) {
+		if h.conf.Algorithm != HashAlgorithmBcrypt {
+			return true
+		}
+
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != h.conf.BcryptCost
+	}
+
+	if h.conf.Algorithm != HashAlgorithmArgon2id {
+		return true
+	}
+
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.memory != h.conf.Memory ||
+		params.iterations != h.conf.Iterations ||
+		params.parallelism != h.conf.Parallelism ||
+		uint32(len(salt)) != h.saltLength() ||
+		uint32(len(key)) != h.keyLength()
+}
+
+func (h *PasswordHasher) saltLength() uint32 {
+	if h.conf.SaltLength == 0 {
+		return 16
+	}
+
+	return h.conf.SaltLength
+}
+
+func (h *PasswordHasher) keyLength() uint32 {
+	if h.conf.KeyLength == 0 {
+		return 32
+	}
+
+	return h.conf.KeyLength
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// encodeArgon2id formats hash as PHC string $argon2id$v=19$m=65536,t=3,p=2$salt$hash
+func encodeArgon2id(params argon2Params, salt []byte, key []byte) string {
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		HashAlgorithmArgon2id, argon2.Version, params.memory, params.iterations, params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	params := argon2Params{}
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != HashAlgorithmArgon2id {
+		return params, nil, nil, eris.New("hash is not argon2id phc string")
+	}
+
+	var version int
+
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return params, nil, nil, eris.New("argon2id version is not supported")
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism)
+	if err != nil || params.memory == 0 || params.iterations == 0 || params.parallelism == 0 {
+		return params, nil, nil, eris.New("argon2id parameters are malformed")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, eris.New("argon2id salt is malformed")
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, eris.New("argon2id hash is malformed")
+	}
+
+	return params, salt, key, nil
+}
+
+// isBcrypt recognizes modular crypt format of bcrypt, $2a$, $2b$ or $2y$
+func isBcrypt(hash string) bool {
+	return len(hash) > 4 && hash[0] == '$' && hash[1] == '2' && hash[3] == '$'
+}
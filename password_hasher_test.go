@@ -0,0 +1,135 @@
+package passport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestHasher(conf PasswordHashingConfiguration) *PasswordHasher {
+	return NewPasswordHasher(&Config{PasswordHashing: conf})
+}
+
+var testArgon2id = PasswordHashingConfiguration{Algorithm: HashAlgorithmArgon2id, Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestArgon2idHashAndVerify(t *testing.T) {
+	hasher := newTestHasher(testArgon2id)
+
+	hash, err := hasher.Hash("Correct-Horse-9")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("unexpected phc string %s", hash)
+	}
+
+	if !hasher.Verify("Correct-Horse-9", hash) {
+		t.Fatal("expected password to match its hash")
+	}
+
+	if hasher.Verify("correct-horse-9", hash) {
+		t.Fatal("expected other password not to match")
+	}
+}
+
+func TestArgon2idEncodeDecode(t *testing.T) {
+	params := argon2Params{memory: 65536, iterations: 3, parallelism: 2}
+	salt := []byte("0123456789abcdef")
+	key := bytes.Repeat([]byte{0xab}, 32)
+
+	hash := encodeArgon2id(params, salt, key)
+
+	expected := "$argon2id$v=19$m=65536,t=3,p=2$MDEyMzQ1Njc4OWFiY2RlZg$q6urq6urq6urq6urq6urq6urq6urq6urq6urq6urq6s"
+	if hash != expected {
+		t.Fatalf("expected %s, got %s", expected, hash)
+	}
+
+	decodedParams, decodedSalt, decodedKey, err := decodeArgon2id(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decodedParams != params || !bytes.Equal(decodedSalt, salt) || !bytes.Equal(decodedKey, key) {
+		t.Fatalf("round trip changed the hash: %+v %x %x", decodedParams, decodedSalt, decodedKey)
+	}
+}
+
+func TestDecodeArgon2idRejectsMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "other algorithm", hash: "$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "other version", hash: "$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "missing parameter", hash: "$argon2id$v=19$m=64,t=1$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "zero memory", hash: "$argon2id$v=19$m=0,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "malformed salt", hash: "$argon2id$v=19$m=64,t=1,p=1$!!$a2V5a2V5"},
+		{name: "empty key", hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$"},
+		{name: "missing part", hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ"},
+		{name: "not phc", hash: "plaintext"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := decodeArgon2id(tt.hash)
+			if err == nil {
+				t.Fatalf("expected %s to be rejected", tt.hash)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon2idHash, err := newTestHasher(testArgon2id).Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moreMemory := testArgon2id
+	moreMemory.Memory = 128
+
+	moreIterations := testArgon2id
+	moreIterations.Iterations = 2
+
+	longerSalt := testArgon2id
+	longerSalt.SaltLength = 32
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bcryptConf := PasswordHashingConfiguration{Algorithm: HashAlgorithmBcrypt, BcryptCost: bcrypt.MinCost}
+
+	higherCost := bcryptConf
+	higherCost.BcryptCost = bcrypt.MinCost + 1
+
+	tests := []struct {
+		name        string
+		conf        PasswordHashingConfiguration
+		hash        string
+		needsRehash bool
+	}{
+		{name: "argon2id with current parameters", conf: testArgon2id, hash: argon2idHash},
+		{name: "argon2id with less memory", conf: moreMemory, hash: argon2idHash, needsRehash: true},
+		{name: "argon2id with fewer iterations", conf: moreIterations, hash: argon2idHash, needsRehash: true},
+		{name: "argon2id with shorter salt", conf: longerSalt, hash: argon2idHash, needsRehash: true},
+		{name: "bcrypt when argon2id is configured", conf: testArgon2id, hash: string(bcryptHash), needsRehash: true},
+		{name: "malformed hash", conf: testArgon2id, hash: "$argon2id$v=19$broken", needsRehash: true},
+		{name: "bcrypt with current cost", conf: bcryptConf, hash: string(bcryptHash)},
+		{name: "bcrypt with lower cost", conf: higherCost, hash: string(bcryptHash), needsRehash: true},
+		{name: "argon2id when bcrypt is configured", conf: bcryptConf, hash: argon2idHash, needsRehash: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			needsRehash := newTestHasher(tt.conf).NeedsRehash(tt.hash)
+			if needsRehash != tt.needsRehash {
+				t.Fatalf("expected needs rehash %v, got %v", tt.needsRehash, needsRehash)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package passport
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/georgi-georgiev/passport/pkg/breach"
+	"github.com/rotisserie/eris"
+	"go.uber.org/zap"
+)
+
+//go:embed common_passwords.txt
+var commonPasswords []byte
+
+// PasswordPolicyError holds every rule the password breaks, so all of them can be reported at once
+type PasswordPolicyError struct {
+	Violations []error
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (e *PasswordPolicyError) Unwrap() []error {
+	return e.Violations
+}
+
+type PasswordPolicy struct {
+	conf       PasswordPolicyConfiguration
+	dictionary map[string]struct{}
+	breached   breach.Checker
+	log        *zap.Logger
+}
+
+func NewPasswordPolicy(conf *Config, log *zap.Logger) *PasswordPolicy {
+	dictionary := make(map[string]struct{})
+
+	if conf.PasswordPolicy.Dictionary {
+		scanner := bufio.NewScanner(bytes.NewReader(commonPasswords))
+		for scanner.Scan() {
+			word := strings.TrimSpace(scanner.Text())
+			if word != "" {
+				dictionary[strings.ToLower(word)] = struct{}{}
+			}
+		}
+	}
+
+	var breached breach.Checker
+	if conf.PasswordPolicy.BreachedPath != "" {
+		var err error
+
+		breached, err = breach.Open(conf.PasswordPolicy.BreachedPath)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return &PasswordPolicy{conf: conf.PasswordPolicy, dictionary: dictionary, breached: breached, log: log}
+}
+
+// Validate checks password against configured rules, identifiers are username and email of the account
+// which must not appear in the password. Nil is returned for acceptable password, otherwise PasswordPolicyError.
+func (p *PasswordPolicy) Validate(password string, identifiers ...string) error {
+	violations := make([]error, 0)
+
+	length := utf8.RuneCountInString(password)
+
+	if length < p.conf.MinLength {
+		violations = append(violations, eris.Errorf("Password must be at least %d characters long", p.conf.MinLength))
+	}
+
+	if p.conf.MaxLength > 0 && length > p.conf.MaxLength {
+		violations = append(violations, eris.Errorf("Password must be at most %d characters long", p.conf.MaxLength))
+	}
+
+	if p.conf.RequireUpper && strings.IndexFunc(password, unicode.IsUpper) < 0 {
+		violations = append(violations, eris.New("Password must contain an uppercase letter"))
+	}
+
+	if p.conf.RequireLower && strings.IndexFunc(password, unicode.IsLower) < 0 {
+		violations = append(violations, eris.New("Password must contain a lowercase letter"))
+	}
+
+	if p.conf.RequireDigit && strings.IndexFunc(password, unicode.IsDigit) < 0 {
+		violations = append(violations, eris.New("Password must contain a digit"))
+	}
+
+	if p.conf.RequireSymbol && strings.IndexFunc(password, isSymbol) < 0 {
+		violations = append(violations, eris.New("Password must contain a symbol"))
+	}
+
+	if p.conf.ForbidIdentifiers && containsIdentifier(password, identifiers) {
+		violations = append(violations, eris.New("Password must not contain username or email"))
+	}
+
+	if p.isCommon(password) {
+		violations = append(violations, eris.New("Password is too common"))
+	}
+
+	if p.isBreached(password) {
+		violations = append(violations, eris.New("Password has appeared in a data breach"))
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+// isCommon looks the password up in the wordlist, also without the digits and symbols people append to meet class rules
+func (p *PasswordPolicy) isCommon(password string) bool {
+	if len(p.dictionary) == 0 {
+		return false
+	}
+
+	lower := strings.ToLower(password)
+	if _, ok := p.dictionary[lower]; ok {
+		return true
+	}
+
+	stem := strings.TrimRightFunc(lower, func(r rune) bool { return unicode.IsDigit(r) || isSymbol(r) })
+	_, ok := p.dictionary[stem]
+
+	return ok
+}
+
+// isBreached looks the password up in local breach dataset, in warn mode matches are only logged
+func (p *PasswordPolicy) isBreached(password string) bool {
+	if p.breached == nil {
+		return false
+	}
+
+	isBreached, err := p.breached.IsBreached(password)
+	if err != nil {
+		p.log.With(zap.Error(err)).Error("could not check breached passwords")
+		return false
+	}
+
+	if isBreached && p.conf.WarnBreached {
+		p.log.Warn("password found in breach dataset accepted")
+		return false
+	}
+
+	return isBreached
+}
+
+func containsIdentifier(password string, identifiers []string) bool {
+	lower := strings.ToLower(password)
+
+	for _, identifier := range identifiers {
+		// only local part of email is something people put in passwords
+		identifier, _, _ = strings.Cut(strings.ToLower(identifier), "@")
+		if utf8.RuneCountInString(identifier) >= 3 && strings.Contains(lower, identifier) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isSymbol(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r)
+}
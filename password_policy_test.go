@@ -0,0 +1,85 @@
+package passport
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := NewPasswordPolicy(&Config{PasswordPolicy: PasswordPolicyConfiguration{
+		MinLength:         10,
+		MaxLength:         20,
+		RequireUpper:      true,
+		RequireLower:      true,
+		RequireDigit:      true,
+		RequireSymbol:     true,
+		ForbidIdentifiers: true,
+		Dictionary:        true,
+	}}, zap.NewNop())
+
+	tests := []struct {
+		name        string
+		password    string
+		identifiers []string
+		violations  []string
+	}{
+		{name: "acceptable", password: "Tr0ub4dor&horse"},
+		{name: "acceptable with short identifier", password: "Tr0ub4dor&horse", identifiers: []string{"or"}},
+		{name: "too short", password: "Sh0rt!pw", violations: []string{"Password must be at least 10 characters long"}},
+		{name: "too long", password: "Tr0ub4dor&horse-battery", violations: []string{"Password must be at most 20 characters long"}},
+		{name: "length counted in characters", password: "Ünïcødé-Pä55wörd"},
+		{name: "no uppercase", password: "tr0ub4dor&horse", violations: []string{"Password must contain an uppercase letter"}},
+		{name: "no lowercase", password: "TR0UB4DOR&HORSE", violations: []string{"Password must contain a lowercase letter"}},
+		{name: "no digit", password: "Troubador&horse", violations: []string{"Password must contain a digit"}},
+		{name: "no symbol", password: "Tr0ub4dorhorse", violations: []string{"Password must contain a symbol"}},
+		{name: "contains username", password: "Tr0ub4dor&Alice", identifiers: []string{"alice"}, violations: []string{"Password must not contain username or email"}},
+		{name: "contains email local part", password: "Tr0ub4dor&Alice", identifiers: []string{"bob", "alice@example.com"}, violations: []string{"Password must not contain username or email"}},
+		{name: "common password", password: "Password1", violations: []string{"Password must be at least 10 characters long", "Password must contain a symbol", "Password is too common"}},
+		{name: "common password with appended digits and symbols", password: "Password123!", violations: []string{"Password is too common"}},
+		{name: "empty", password: "", violations: []string{
+			"Password must be at least 10 characters long",
+			"Password must contain an uppercase letter",
+			"Password must contain a lowercase letter",
+			"Password must contain a digit",
+			"Password must contain a symbol",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, tt.identifiers...)
+
+			if len(tt.violations) == 0 {
+				if err != nil {
+					t.Fatalf("expected password to be accepted, got %v", err)
+				}
+
+				return
+			}
+
+			policyErr, ok := err.(*PasswordPolicyError)
+			if !ok {
+				t.Fatalf("expected PasswordPolicyError, got %v", err)
+			}
+
+			if len(policyErr.Violations) != len(tt.violations) {
+				t.Fatalf("expected violations %v, got %v", tt.violations, policyErr)
+			}
+
+			for i, violation := range policyErr.Violations {
+				if violation.Error() != tt.violations[i] {
+					t.Fatalf("expected violation %q, got %q", tt.violations[i], violation.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyWithoutDictionary(t *testing.T) {
+	policy := NewPasswordPolicy(&Config{PasswordPolicy: PasswordPolicyConfiguration{MinLength: 8}}, zap.NewNop())
+
+	if err := policy.Validate("password"); err != nil {
+		t.Fatalf("expected common password to be accepted without dictionary, got %v", err)
+	}
+}
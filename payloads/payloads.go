@@ -15,6 +15,10 @@ type PasswordResetPayload struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type LogoutPayload struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
 type InviteUserPayload struct {
 	Email string `json:"email"`
 }
@@ -32,6 +36,9 @@ type UpdateUserPayload struct {
 	Username             string `json:"username"`
 	IsActive             bool   `json:"isActive"`
 	ShouldChangePassword bool   `json:"shoudlChangePassword"`
+	// Role and Rights are left unchanged when omitted
+	Role   string   `json:"role"`
+	Rights []string `json:"rights"`
 }
 
 type CreateRolePayload struct {
@@ -49,3 +56,71 @@ type CreateRightPayload struct {
 type UpdateRightPayload struct {
 	Name string `json:"name" binding:"required"`
 }
+
+type CreateClientPayload struct {
+	Name         string   `json:"name" binding:"required"`
+	IsPublic     bool     `json:"isPublic"`
+	RedirectURIs []string `json:"redirectUris"`
+	GrantTypes   []string `json:"grantTypes" binding:"required"`
+	Scopes       []string `json:"scopes"`
+	Audiences    []string `json:"audiences"`
+	Role         string   `json:"role"`
+	Rights       []string `json:"rights"`
+}
+
+type VerifyDeviceCodePayload struct {
+	UserCode string `json:"userCode" binding:"required"`
+	Approve  bool   `json:"approve"`
+}
+
+type TotpCodePayload struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type VerifyMfaPayload struct {
+	MfaToken string `json:"mfaToken" binding:"required"`
+	Method   string `json:"method"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type WebAuthnAttestationPayload struct {
+	ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+	AttestationObject string   `json:"attestationObject" binding:"required"`
+	Transports        []string `json:"transports"`
+}
+
+type WebAuthnRegistrationPayload struct {
+	ID       string                     `json:"id" binding:"required"`
+	Type     string                     `json:"type"`
+	Name     string                     `json:"name"`
+	Response WebAuthnAttestationPayload `json:"response" binding:"required"`
+}
+
+type WebAuthnLoginBeginPayload struct {
+	Username string `json:"username"`
+	Scope    string `json:"scope"`
+}
+
+type WebAuthnAssertionPayload struct {
+	ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+	AuthenticatorData string `json:"authenticatorData" binding:"required"`
+	Signature         string `json:"signature" binding:"required"`
+	UserHandle        string `json:"userHandle"`
+}
+
+type WebAuthnLoginPayload struct {
+	ID       string                   `json:"id" binding:"required"`
+	Type     string                   `json:"type"`
+	Response WebAuthnAssertionPayload `json:"response" binding:"required"`
+}
+
+type PasswordlessStartPayload struct {
+	Email string `json:"email" binding:"required,email"`
+	Scope string `json:"scope"`
+}
+
+type PasswordlessVerifyPayload struct {
+	Email string `json:"email"`
+	Code  string `json:"code"`
+	Token string `json:"token"`
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/georgi-georgiev/passport"
+	"github.com/georgi-georgiev/passport/audit"
 	"github.com/georgi-georgiev/passport/payloads"
 	"github.com/rotisserie/eris"
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,13 +13,14 @@ import (
 )
 
 type RightService struct {
-	repository *RightRepository
-	conf       *passport.Config
-	log        *zap.Logger
+	repository   *RightRepository
+	auditService *audit.AuditService
+	conf         *passport.Config
+	log          *zap.Logger
 }
 
-func NewRightService(repository *RightRepository, conf *passport.Config, log *zap.Logger) *RightService {
-	return &RightService{repository: repository, conf: conf, log: log}
+func NewRightService(repository *RightRepository, auditService *audit.AuditService, conf *passport.Config, log *zap.Logger) *RightService {
+	return &RightService{repository: repository, auditService: auditService, conf: conf, log: log}
 }
 
 func (s *RightService) CreateRight(ctx context.Context, payload payloads.CreateRightPayload) (*Right, error) {
@@ -27,11 +29,13 @@ func (s *RightService) CreateRight(ctx context.Context, payload payloads.CreateR
 		Name: payload.Name,
 	}
 
-	_, err := s.repository.Create(ctx, right)
+	id, err := s.repository.Create(ctx, right)
 	if err != nil {
 		return nil, err
 	}
 
+	s.auditService.Success(ctx, audit.ActionRightCreate, id.Hex(), map[string]string{"name": right.Name})
+
 	return right, nil
 }
 
@@ -88,6 +92,8 @@ func (s *RightService) UpdateRight(ctx context.Context, id primitive.ObjectID, n
 		return nil, nil
 	}
 
+	previousName := r.Name
+
 	if r.Name != name {
 		r.Name = name
 	}
@@ -97,5 +103,7 @@ func (s *RightService) UpdateRight(ctx context.Context, id primitive.ObjectID, n
 		return nil, err
 	}
 
+	s.auditService.Success(ctx, audit.ActionRightUpdate, id.Hex(), map[string]string{"previousName": previousName, "name": r.Name})
+
 	return r, nil
 }
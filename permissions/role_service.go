@@ -3,17 +3,19 @@ package permissions
 import (
 	"context"
 
+	"github.com/georgi-georgiev/passport/audit"
 	"github.com/georgi-georgiev/passport/payloads"
 	"github.com/rotisserie/eris"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type RoleService struct {
-	repository *RoleRepository
+	repository   *RoleRepository
+	auditService *audit.AuditService
 }
 
-func NewRoleService(repository *RoleRepository) *RoleService {
-	return &RoleService{repository: repository}
+func NewRoleService(repository *RoleRepository, auditService *audit.AuditService) *RoleService {
+	return &RoleService{repository: repository, auditService: auditService}
 }
 
 func (s *RoleService) CreateRole(ctx context.Context, payload payloads.CreateRolePayload) (*Role, error) {
@@ -22,11 +24,13 @@ func (s *RoleService) CreateRole(ctx context.Context, payload payloads.CreateRol
 		Name: payload.Name,
 	}
 
-	_, err := s.repository.Create(ctx, role)
+	id, err := s.repository.Create(ctx, role)
 	if err != nil {
 		return nil, err
 	}
 
+	s.auditService.Success(ctx, audit.ActionRoleCreate, id.Hex(), map[string]string{"name": role.Name})
+
 	return role, nil
 }
 
@@ -65,6 +69,8 @@ func (s *RoleService) UpdateRole(ctx context.Context, id primitive.ObjectID, nam
 		return nil, nil
 	}
 
+	previousName := r.Name
+
 	if r.Name != name {
 		r.Name = name
 	}
@@ -74,5 +80,7 @@ func (s *RoleService) UpdateRole(ctx context.Context, id primitive.ObjectID, nam
 		return nil, err
 	}
 
+	s.auditService.Success(ctx, audit.ActionRoleUpdate, id.Hex(), map[string]string{"previousName": previousName, "name": r.Name})
+
 	return r, nil
 }
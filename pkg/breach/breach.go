@@ -0,0 +1,70 @@
+// Package breach checks passwords against a local copy of the Have I Been Pwned password dataset,
+// either the directory of SHA-1 range files or a bloom filter built from the full hash list.
+package breach
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Checker reports whether password appears in the dataset
+type Checker interface {
+	IsBreached(password string) (bool, error)
+}
+
+// Open loads checker for the path, directory is read as range files and regular file as filter
+func Open(path string) (Checker, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open breached passwords: %w", err)
+	}
+
+	if info.IsDir() {
+		return RangeDirectory(path), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open breached passwords: %w", err)
+	}
+	defer file.Close()
+
+	return ReadFilter(bufio.NewReader(file))
+}
+
+// RangeDirectory is directory of files named by the first five hex characters of SHA-1,
+// each holding SUFFIX:COUNT lines as downloaded from the range API
+type RangeDirectory string
+
+func (d RangeDirectory) IsBreached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(string(d), prefix+".txt"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineSuffix, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+
+		// padded range responses contain fake suffixes with zero count
+		if strings.EqualFold(lineSuffix, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
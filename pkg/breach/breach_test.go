@@ -0,0 +1,92 @@
+package breach
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func hashLine(password string, count int) string {
+	sum := sha1.Sum([]byte(password))
+	return fmt.Sprintf("%s:%d", strings.ToUpper(hex.EncodeToString(sum[:])), count)
+}
+
+func TestFilterRoundTrip(t *testing.T) {
+	breached := []string{"password", "123456", "hunter2"}
+
+	lines := make([]string, 0)
+	for _, password := range breached {
+		lines = append(lines, hashLine(password, 10))
+	}
+
+	filter, err := BuildFilter(strings.NewReader(strings.Join(lines, "\n")), uint64(len(lines)), 0.0001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	_, err = filter.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := ReadFilter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, password := range breached {
+		isBreached, _ := loaded.IsBreached(password)
+		if !isBreached {
+			t.Fatalf("expected %s to be breached", password)
+		}
+	}
+
+	isBreached, _ := loaded.IsBreached("Correct-Horse-9-Battery")
+	if isBreached {
+		t.Fatal("expected unknown password not to be breached")
+	}
+}
+
+func TestBuildFilterRejectsMalformedLines(t *testing.T) {
+	_, err := BuildFilter(strings.NewReader("not-a-hash:1"), 1, 0.001)
+	if err == nil {
+		t.Fatal("expected malformed line to be rejected")
+	}
+}
+
+func TestRangeDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	sum := sha1.Sum([]byte("password"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	padding := strings.Repeat("0", 35) + ":0"
+	content := hash[5:] + ":9545824\r\n" + padding + "\r\n"
+
+	err := os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checker, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isBreached, err := checker.IsBreached("password")
+	if err != nil || !isBreached {
+		t.Fatalf("expected password to be breached, got %v %v", isBreached, err)
+	}
+
+	isBreached, err = checker.IsBreached("Correct-Horse-9-Battery")
+	if err != nil || isBreached {
+		t.Fatalf("expected unknown password not to be breached, got %v %v", isBreached, err)
+	}
+}
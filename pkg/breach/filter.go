@@ -0,0 +1,147 @@
+package breach
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+var filterMagic = [4]byte{'P', 'B', 'F', '1'}
+
+// Filter is bloom filter over SHA-1 hashes, lookups may give false positives at the rate chosen on build but never false negatives
+type Filter struct {
+	bits   []uint64
+	size   uint64
+	hashes uint32
+}
+
+// NewFilter sizes filter for expected number of hashes and false positive rate
+func NewFilter(expected uint64, falsePositiveRate float64) *Filter {
+	if expected == 0 {
+		expected = 1
+	}
+
+	size := uint64(math.Ceil(-float64(expected) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	hashes := uint32(math.Max(1, math.Round(float64(size)/float64(expected)*math.Ln2)))
+
+	return &Filter{bits: make([]uint64, (size+63)/64), size: size, hashes: hashes}
+}
+
+// Add inserts SHA-1 hash into the filter
+func (f *Filter) Add(hash [sha1.Size]byte) {
+	h1, h2 := split(hash)
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		bit := (h1 + i*h2) % f.size
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Contains reports whether SHA-1 hash was probably added
+func (f *Filter) Contains(hash [sha1.Size]byte) bool {
+	h1, h2 := split(hash)
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		bit := (h1 + i*h2) % f.size
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f *Filter) IsBreached(password string) (bool, error) {
+	return f.Contains(sha1.Sum([]byte(password))), nil
+}
+
+// WriteTo stores the filter in binary form read by ReadFilter
+func (f *Filter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, 0, 16)
+	header = append(header, filterMagic[:]...)
+	header = binary.BigEndian.AppendUint64(header, f.size)
+	header = binary.BigEndian.AppendUint32(header, f.hashes)
+
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+
+	err = binary.Write(w, binary.BigEndian, f.bits)
+	if err != nil {
+		return int64(n), err
+	}
+
+	return int64(n + len(f.bits)*8), nil
+}
+
+func ReadFilter(r io.Reader) (*Filter, error) {
+	header := make([]byte, 16)
+
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, fmt.Errorf("could not read filter header: %w", err)
+	}
+
+	if [4]byte(header[:4]) != filterMagic {
+		return nil, errors.New("file is not a breached passwords filter")
+	}
+
+	f := &Filter{size: binary.BigEndian.Uint64(header[4:12]), hashes: binary.BigEndian.Uint32(header[12:16])}
+	if f.size == 0 || f.hashes == 0 {
+		return nil, errors.New("breached passwords filter is empty")
+	}
+
+	f.bits = make([]uint64, (f.size+63)/64)
+
+	err = binary.Read(r, binary.BigEndian, f.bits)
+	if err != nil {
+		return nil, fmt.Errorf("could not read filter: %w", err)
+	}
+
+	return f, nil
+}
+
+// BuildFilter reads HASH:COUNT lines of the full SHA-1 dataset, expected is number of lines used to size the filter
+func BuildFilter(r io.Reader, expected uint64, falsePositiveRate float64) (*Filter, error) {
+	f := NewFilter(expected, falsePositiveRate)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		encoded, _, _ := strings.Cut(line, ":")
+
+		if len(encoded) != hex.EncodedLen(sha1.Size) {
+			return nil, fmt.Errorf("line %q is not a SHA-1 hash", line)
+		}
+
+		var hash [sha1.Size]byte
+
+		_, err := hex.Decode(hash[:], []byte(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("line %q is not a SHA-1 hash", line)
+		}
+
+		f.Add(hash)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// split derives two independent hash values from SHA-1, which is already uniformly distributed
+func split(hash [sha1.Size]byte) (uint64, uint64) {
+	return binary.BigEndian.Uint64(hash[0:8]), binary.BigEndian.Uint64(hash[8:16]) | 1
+}
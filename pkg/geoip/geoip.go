@@ -0,0 +1,156 @@
+// Package geoip locates IP addresses using a local copy of the DB-IP lite CSV databases,
+// either the country database with ip_start,ip_end,country rows or the city database
+// that adds continent, region, city, latitude and longitude.
+package geoip
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Location is where the address is registered, coordinates are known only with the city database
+type Location struct {
+	Country        string
+	Latitude       float64
+	Longitude      float64
+	HasCoordinates bool
+}
+
+type ipRange struct {
+	start    netip.Addr
+	end      netip.Addr
+	location Location
+}
+
+// Database holds ranges sorted by start address
+type Database struct {
+	ranges []ipRange
+}
+
+// Open loads database from the csv file
+func Open(path string) (*Database, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open geoip database: %w", err)
+	}
+	defer file.Close()
+
+	return Read(bufio.NewReaderSize(file, 1<<20))
+}
+
+// Read parses database rows, ranges may come in any order but must not overlap
+func Read(r io.Reader) (*Database, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	ranges := make([]ipRange, 0)
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not read geoip database: %w", err)
+		}
+
+		ipRange, err := parseRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("geoip database line %d: %w", line, err)
+		}
+
+		ranges = append(ranges, ipRange)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start.Less(ranges[j].start)
+	})
+
+	return &Database{ranges: ranges}, nil
+}
+
+func parseRecord(record []string) (ipRange, error) {
+	if len(record) != 3 && len(record) != 8 {
+		return ipRange{}, fmt.Errorf("expected 3 or 8 columns, got %d", len(record))
+	}
+
+	start, err := netip.ParseAddr(record[0])
+	if err != nil {
+		return ipRange{}, err
+	}
+
+	end, err := netip.ParseAddr(record[1])
+	if err != nil {
+		return ipRange{}, err
+	}
+
+	if start.Is4() != end.Is4() || end.Less(start) {
+		return ipRange{}, fmt.Errorf("invalid range %s - %s", start, end)
+	}
+
+	if len(record) == 3 {
+		return ipRange{start: start, end: end, location: Location{Country: record[2]}}, nil
+	}
+
+	latitude, err := strconv.ParseFloat(record[6], 64)
+	if err != nil {
+		return ipRange{}, err
+	}
+
+	longitude, err := strconv.ParseFloat(record[7], 64)
+	if err != nil {
+		return ipRange{}, err
+	}
+
+	return ipRange{start: start, end: end, location: Location{Country: record[3], Latitude: latitude, Longitude: longitude, HasCoordinates: true}}, nil
+}
+
+// Lookup returns location of the address, false when the address is not in the database
+func (d *Database) Lookup(ip string) (Location, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return Location{}, false
+	}
+
+	addr = addr.Unmap()
+
+	// first range starting after the address, the candidate is the one before it
+	i := sort.Search(len(d.ranges), func(i int) bool {
+		return addr.Less(d.ranges[i].start)
+	})
+
+	if i == 0 {
+		return Location{}, false
+	}
+
+	candidate := d.ranges[i-1]
+	if candidate.end.Less(addr) || candidate.start.Is4() != addr.Is4() {
+		return Location{}, false
+	}
+
+	return candidate.location, true
+}
+
+const earthRadius = 6371.0
+
+// Distance returns great-circle distance between the locations in kilometers
+func Distance(a Location, b Location) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
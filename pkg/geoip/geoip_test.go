@@ -0,0 +1,72 @@
+package geoip
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const countryDatabase = `1.0.0.0,1.0.0.255,AU
+8.8.8.0,8.8.8.255,US
+2001:db8::,2001:db8:ffff:ffff:ffff:ffff:ffff:ffff,NL
+5.5.0.0,5.5.255.255,DE
+`
+
+func TestLookup(t *testing.T) {
+	database, err := Read(strings.NewReader(countryDatabase))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip      string
+		country string
+		found   bool
+	}{
+		{"1.0.0.1", "AU", true},
+		{"8.8.8.8", "US", true},
+		{"5.5.128.1", "DE", true},
+		{"::ffff:8.8.8.8", "US", true},
+		{"2001:db8::1", "NL", true},
+		{"0.255.255.255", "", false},
+		{"8.8.9.0", "", false},
+		{"2001:db9::1", "", false},
+		{"not an ip", "", false},
+	}
+
+	for _, test := range tests {
+		location, found := database.Lookup(test.ip)
+		if found != test.found || location.Country != test.country {
+			t.Errorf("Lookup(%s) = %s, %t, want %s, %t", test.ip, location.Country, found, test.country, test.found)
+		}
+	}
+}
+
+func TestCityDatabase(t *testing.T) {
+	database, err := Read(strings.NewReader(`8.8.8.0,8.8.8.255,NA,US,California,Mountain View,37.4056,-122.0775`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location, found := database.Lookup("8.8.8.8")
+	if !found || !location.HasCoordinates || location.Country != "US" {
+		t.Fatalf("unexpected location %+v", location)
+	}
+}
+
+func TestReadRejectsInvalidRange(t *testing.T) {
+	_, err := Read(strings.NewReader(`8.8.8.255,8.8.8.0,US`))
+	if err == nil {
+		t.Fatal("expected error for reversed range")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	london := Location{Latitude: 51.5074, Longitude: -0.1278}
+	paris := Location{Latitude: 48.8566, Longitude: 2.3522}
+
+	distance := Distance(london, paris)
+	if math.Abs(distance-344) > 5 {
+		t.Fatalf("Distance = %f, want about 344 km", distance)
+	}
+}
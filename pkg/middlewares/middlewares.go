@@ -1,11 +1,17 @@
 package middlewares
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/georgi-georgiev/blunder"
+	"github.com/georgi-georgiev/passport"
+	"github.com/georgi-georgiev/passport/audit"
+	"github.com/georgi-georgiev/passport/clients"
 	"github.com/georgi-georgiev/passport/permissions"
+	"github.com/georgi-georgiev/passport/responses"
 	"github.com/georgi-georgiev/passport/users"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,14 +20,16 @@ import (
 )
 
 type IdentityMiddleware struct {
-	userRervice  *users.UserService
-	roleService  *permissions.RoleService
-	rightService *permissions.RightService
-	log          *zap.Logger
+	userRervice   *users.UserService
+	clientService *clients.ClientService
+	roleService   *permissions.RoleService
+	rightService  *permissions.RightService
+	auditService  *audit.AuditService
+	log           *zap.Logger
 }
 
-func NewMiddleware(userRervice *users.UserService, roleServce *permissions.RoleService, rightService *permissions.RightService, log *zap.Logger) *IdentityMiddleware {
-	return &IdentityMiddleware{userRervice: userRervice, roleService: roleServce, rightService: rightService, log: log}
+func NewMiddleware(userRervice *users.UserService, clientService *clients.ClientService, roleServce *permissions.RoleService, rightService *permissions.RightService, auditService *audit.AuditService, log *zap.Logger) *IdentityMiddleware {
+	return &IdentityMiddleware{userRervice: userRervice, clientService: clientService, roleService: roleServce, rightService: rightService, auditService: auditService, log: log}
 }
 
 func (m *IdentityMiddleware) Authenticate() gin.HandlerFunc {
@@ -38,11 +46,46 @@ func (m *IdentityMiddleware) Authenticate() gin.HandlerFunc {
 		userClaims, err := m.userRervice.ValidateToken(c.Request.Context(), token)
 		if err != nil {
 			m.log.With(zap.Error(err)).Error("could not validate token")
+			m.auditService.Failure(c.Request.Context(), audit.ActionAuthenticate, "", "token is invalid")
 			c.AbortWithStatusJSON(http.StatusUnauthorized, blunder.Unauthorized())
 			return
 		}
 
-		userID, err := primitive.ObjectIDFromHex(userClaims.Id)
+		// delegated and impersonation tokens are meant for the service they were exchanged for, never for managing accounts here
+		if userClaims.Act != nil {
+			m.log.Info("token with actor rejected", zap.String("userId", userClaims.Subject), zap.String("actor", userClaims.Act.Subject))
+			m.auditService.Failure(c.Request.Context(), audit.ActionAuthenticate, userClaims.Subject, "token is delegated")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, blunder.Unauthorized())
+			return
+		}
+
+		// machine tokens issued through client credentials grant have no user behind them
+		if userClaims.IsClient {
+			client, err := m.clientService.GetByClientID(c.Request.Context(), userClaims.Subject)
+			if err != nil {
+				m.log.With(zap.Error(err)).Error("could not get client")
+				c.AbortWithStatusJSON(http.StatusUnauthorized, blunder.Unauthorized())
+				return
+			}
+
+			if client == nil {
+				m.log.Error("client does not exist or is not active")
+				m.auditService.Failure(c.Request.Context(), audit.ActionAuthenticate, userClaims.Subject, "client is not active")
+				c.AbortWithStatusJSON(http.StatusUnauthorized, blunder.Unauthorized())
+				return
+			}
+
+			c.Set("claims", userClaims)
+			c.Set("clientId", client.ClientID)
+			c.Set("roleId", userClaims.RoleId)
+			c.Set("rights", userClaims.Rights)
+			passport.SetRequestActor(c, client.ClientID, client.ClientID)
+
+			c.Next()
+			return
+		}
+
+		userID, err := primitive.ObjectIDFromHex(userClaims.Subject)
 		if err != nil {
 			m.log.With(zap.Error(err)).Error("could not convert user id hex to primitive")
 			c.AbortWithStatusJSON(http.StatusUnauthorized, blunder.Unauthorized())
@@ -58,33 +101,100 @@ func (m *IdentityMiddleware) Authenticate() gin.HandlerFunc {
 
 		if user == nil {
 			m.log.With(zap.Error(err)).Error("user does not exist")
+			m.auditService.Failure(c.Request.Context(), audit.ActionAuthenticate, userClaims.Subject, "user does not exist")
 			c.AbortWithStatusJSON(http.StatusUnauthorized, blunder.Unauthorized())
 			return
 		}
 
 		if !user.IsActive {
 			m.log.With(zap.Error(err)).Error("user is not active")
+			m.auditService.Failure(c.Request.Context(), audit.ActionAuthenticate, userClaims.Subject, "user is not active")
 			c.AbortWithStatusJSON(http.StatusUnauthorized, blunder.Unauthorized())
 			return
 		}
 
 		if !user.IsVerified {
 			m.log.With(zap.Error(err)).Error("user is not verified")
+			m.auditService.Failure(c.Request.Context(), audit.ActionAuthenticate, userClaims.Subject, "user is not verified")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, blunder.Unauthorized())
+			return
+		}
+
+		if !user.HasTokenVersion(userClaims.TokenVersion) {
+			m.log.Info("token was issued before credentials or privileges changed", zap.String("userId", userClaims.Subject))
+			m.auditService.Failure(c.Request.Context(), audit.ActionAuthenticate, userClaims.Subject, "token version is outdated")
 			c.AbortWithStatusJSON(http.StatusUnauthorized, blunder.Unauthorized())
 			return
 		}
 
-		c.Set("userId", userClaims.Id)
+		c.Set("claims", userClaims)
+		c.Set("userId", userClaims.Subject)
+		c.Set("clientId", userClaims.ClientID)
 		c.Set("roleId", userClaims.RoleId)
 		c.Set("rights", userClaims.Rights)
+		c.Set("authTime", userClaims.AuthTime)
+		c.Set("amr", userClaims.Amr)
+		c.Set("sessionId", userClaims.SessionID)
+		passport.SetRequestActor(c, userClaims.Subject, userClaims.ClientID)
+
+		c.Next()
+	}
+}
+
+// RequireSessionToken admits only token of the user's own login session, endpoints acting with full authority
+// of the user must not be reachable with tokens handed to other parties
+func (m *IdentityMiddleware) RequireSessionToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := c.Value("claims").(*passport.UserClaims)
+		if !ok || !claims.IsSessionToken() {
+			m.log.Info("token does not stand for user session", zap.String("path", c.FullPath()))
+			c.AbortWithStatusJSON(http.StatusForbidden, blunder.Forbidden())
+			return
+		}
 
 		c.Next()
 	}
 }
 
+// RequireAuthLevel demands that the user authenticated within maxAge using any of the methods,
+// asking the client to re-authenticate otherwise. Zero maxAge skips the age check.
+func (m *IdentityMiddleware) RequireAuthLevel(maxAge time.Duration, methods ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		amr := c.GetStringSlice("amr")
+		authTime := time.Unix(c.GetInt64("authTime"), 0)
+
+		hasMethod := len(methods) == 0
+		for _, method := range methods {
+			if slices.Contains(amr, method) {
+				hasMethod = true
+				break
+			}
+		}
+
+		isRecent := maxAge == 0 || (c.GetInt64("authTime") > 0 && time.Since(authTime) <= maxAge)
+
+		if hasMethod && isRecent {
+			c.Next()
+			return
+		}
+
+		m.log.Info("insufficient user authentication", zap.String("userId", c.GetString("userId")), zap.Strings("amr", amr), zap.Time("authTime", authTime))
+
+		description := "Recent authentication with a stronger method is required"
+		c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_user_authentication", error_description="%s", max_age=%d`, description, int64(maxAge.Seconds())))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, responses.InsufficientAuthenticationResponse{
+			Error:            "insufficient_user_authentication",
+			ErrorDescription: description,
+			MaxAge:           int64(maxAge.Seconds()),
+			AcrValues:        passport.AcrMultiFactor,
+			AmrValues:        methods,
+		})
+	}
+}
+
 func (m *IdentityMiddleware) Authorize(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		r := c.GetString("role")
+		r := c.GetString("roleId")
 		roleId, err := primitive.ObjectIDFromHex(r)
 		if err != nil {
 			m.log.With(zap.Error(err)).Error("could not convert role id from hex to primitive object")
@@ -115,6 +225,7 @@ func (m *IdentityMiddleware) Authorize(roles ...string) gin.HandlerFunc {
 
 		if !roleExists {
 			m.log.With(zap.Error(err)).Error("role is not containing in the list", zap.String("name", rr.Name), zap.Strings("roles", roles))
+			m.auditService.Record(c.Request.Context(), &audit.Event{Action: audit.ActionAuthorize, Outcome: audit.OutcomeFailure, Reason: "role is not allowed", Details: map[string]string{"role": rr.Name, "path": c.FullPath()}})
 			c.AbortWithStatusJSON(http.StatusForbidden, blunder.Forbidden())
 			return
 		}
@@ -156,6 +267,7 @@ func (m *IdentityMiddleware) AuthorizeSpecific(rrs []string) gin.HandlerFunc {
 		}
 
 		if !rightExists {
+			m.auditService.Record(c.Request.Context(), &audit.Event{Action: audit.ActionAuthorize, Outcome: audit.OutcomeFailure, Reason: "right is missing", Details: map[string]string{"path": c.FullPath()}})
 			c.AbortWithStatusJSON(http.StatusForbidden, blunder.Forbidden())
 			return
 		}
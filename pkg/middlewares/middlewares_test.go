@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/georgi-georgiev/passport"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestRequireSessionToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	session := func() *passport.UserClaims {
+		return &passport.UserClaims{SessionID: "65a1b2c3d4e5f60718293a4b"}
+	}
+
+	tests := []struct {
+		name   string
+		claims func() *passport.UserClaims
+		status int
+	}{
+		{name: "session token", claims: session, status: http.StatusNoContent},
+		{name: "no claims", claims: func() *passport.UserClaims { return nil }, status: http.StatusForbidden},
+		{name: "token without session", claims: func() *passport.UserClaims { return &passport.UserClaims{} }, status: http.StatusForbidden},
+		{name: "machine token", claims: func() *passport.UserClaims {
+			claims := session()
+			claims.IsClient = true
+			return claims
+		}, status: http.StatusForbidden},
+		{name: "delegated token", claims: func() *passport.UserClaims {
+			claims := session()
+			claims.Act = &passport.Actor{Subject: "api", ClientID: "api"}
+			return claims
+		}, status: http.StatusForbidden},
+		{name: "token for other audience", claims: func() *passport.UserClaims {
+			claims := session()
+			claims.Audience = "https://api.example.com"
+			return claims
+		}, status: http.StatusForbidden},
+	}
+
+	middleware := &IdentityMiddleware{log: zap.NewNop()}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := gin.New()
+			engine.POST("/device", func(c *gin.Context) {
+				if claims := tt.claims(); claims != nil {
+					c.Set("claims", claims)
+				}
+			}, middleware.RequireSessionToken(), func(c *gin.Context) {
+				c.Status(http.StatusNoContent)
+			})
+
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/device", nil))
+
+			if recorder.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, recorder.Code)
+			}
+		})
+	}
+}
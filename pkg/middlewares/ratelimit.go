@@ -0,0 +1,159 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+const (
+	RateLimitBackendMemory = "memory"
+	RateLimitBackendMongo  = "mongo"
+)
+
+// RateLimitStore keeps token buckets, Take removes one token from the bucket of the key
+// and when it is empty returns how long until the next token is added
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, rule passport.RateLimitRule) (bool, time.Duration, error)
+}
+
+// rateLimitStoreErrors counts requests let through unlimited because the store failed
+var rateLimitStoreErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "passport_rate_limit_store_errors_total",
+	Help: "Requests let through without rate limiting because the rate limit store failed.",
+}, []string{"rule"})
+
+// KeyFunc extracts what the request is limited by, empty key skips the limit
+type KeyFunc func(c *gin.Context) string
+
+type RateLimiter struct {
+	store RateLimitStore
+	log   *zap.Logger
+}
+
+func NewRateLimiter(client *mongo.Client, conf *passport.Config, log *zap.Logger) *RateLimiter {
+	var store RateLimitStore
+
+	switch conf.RateLimit.Backend {
+	case RateLimitBackendMongo:
+		store = NewMongoRateLimitStore(client, conf)
+	case "", RateLimitBackendMemory:
+		store = NewMemoryRateLimitStore()
+	default:
+		panic("unknown rate limit backend " + conf.RateLimit.Backend)
+	}
+
+	return &RateLimiter{store: store, log: log}
+}
+
+// Limit throttles requests sharing the key to the rule, answering 429 with Retry-After once the bucket is empty.
+// Name separates buckets of different rules using the same key.
+func (l *RateLimiter) Limit(name string, rule passport.RateLimitRule, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rule.Requests <= 0 {
+			c.Next()
+			return
+		}
+
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+
+		allowed, retryAfter, err := l.store.Take(c.Request.Context(), name+":"+k, rule)
+		if err != nil {
+			// limiter outage must not take login down with it, but brute-force protection is off until the store is back
+			l.log.With(zap.Error(err)).Warn("could not take rate limit token, letting request through", zap.String("rule", name))
+			rateLimitStoreErrors.WithLabelValues(name).Inc()
+			c.Next()
+			return
+		}
+
+		if !allowed {
+			l.log.Info("rate limit exceeded", zap.String("rule", name), zap.String("key", k), zap.String("path", c.FullPath()))
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// maxKeyBodySize caps how much of the body is read to find the key, payloads of limited endpoints are far smaller
+const maxKeyBodySize = 64 << 10
+
+func ByIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// ByEmail limits by email field of json body, leaving the body readable for the handler
+func ByEmail(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxKeyBodySize))
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var payload struct {
+		Email string `json:"email"`
+	}
+
+	if json.Unmarshal(body, &payload) != nil {
+		return ""
+	}
+
+	return strings.ToLower(strings.TrimSpace(payload.Email))
+}
+
+// ByClientID limits by client id presented with basic auth or client_id form field, falling back to client address.
+// The secret is not verified here, hashing it for every request would make a flood of made up secrets cost
+// a hash each even once limited, so the limit by address has to come first.
+func ByClientID(c *gin.Context) string {
+	clientID, _, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID = c.PostForm("client_id")
+	}
+
+	if clientID == "" {
+		return "ip:" + c.ClientIP()
+	}
+
+	return "client:" + clientID
+}
+
+// rate returns tokens added to the bucket per second
+func rate(rule passport.RateLimitRule) float64 {
+	return float64(rule.Requests) / rule.Per.Seconds()
+}
+
+func burst(rule passport.RateLimitRule) float64 {
+	if rule.Burst > 0 {
+		return float64(rule.Burst)
+	}
+
+	return float64(rule.Requests)
+}
+
+// wait returns time until the bucket holding tokens has one whole token
+func wait(tokens float64, rule passport.RateLimitRule) time.Duration {
+	return time.Duration((1 - tokens) / rate(rule) * float64(time.Second))
+}
@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+)
+
+type bucket struct {
+	tokens    float64
+	updatedOn time.Time
+	expiresOn time.Time
+}
+
+// MemoryRateLimitStore keeps buckets in process memory, every replica limits on its own
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	cleanedOn time.Time
+	now       func() time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*bucket), cleanedOn: time.Now(), now: time.Now}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, rule passport.RateLimitRule) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.cleanup(now)
+
+	capacity := burst(rule)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updatedOn: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.updatedOn).Seconds() * rate(rule)
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+
+	b.updatedOn = now
+	// full bucket is the same as no bucket, so it can be dropped once refilled
+	b.expiresOn = now.Add(time.Duration((capacity - b.tokens + 1) / rate(rule) * float64(time.Second)))
+
+	if b.tokens < 1 {
+		return false, wait(b.tokens, rule), nil
+	}
+
+	b.tokens--
+
+	return true, 0, nil
+}
+
+// cleanup drops refilled buckets at most once a minute so memory does not grow with every seen key
+func (s *MemoryRateLimitStore) cleanup(now time.Time) {
+	if now.Sub(s.cleanedOn) < time.Minute {
+		return
+	}
+
+	for key, b := range s.buckets {
+		if now.After(b.expiresOn) {
+			delete(s.buckets, key)
+		}
+	}
+
+	s.cleanedOn = now
+}
@@ -0,0 +1,107 @@
+package middlewares
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+)
+
+func TestMemoryRateLimitStoreTake(t *testing.T) {
+	type take struct {
+		after      time.Duration
+		allowed    bool
+		retryAfter time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		rule  passport.RateLimitRule
+		takes []take
+	}{
+		{
+			name: "requests up to the limit",
+			rule: passport.RateLimitRule{Requests: 2, Per: time.Minute},
+			takes: []take{
+				{allowed: true},
+				{allowed: true},
+				{allowed: false, retryAfter: 30 * time.Second},
+			},
+		},
+		{
+			name: "refill after a token period",
+			rule: passport.RateLimitRule{Requests: 2, Per: time.Minute},
+			takes: []take{
+				{allowed: true},
+				{allowed: true},
+				{after: 20 * time.Second, allowed: false, retryAfter: 10 * time.Second},
+				{after: 10 * time.Second, allowed: true},
+				{allowed: false, retryAfter: 30 * time.Second},
+			},
+		},
+		{
+			name: "burst above rate",
+			rule: passport.RateLimitRule{Requests: 1, Per: time.Minute, Burst: 3},
+			takes: []take{
+				{allowed: true},
+				{allowed: true},
+				{allowed: true},
+				{allowed: false, retryAfter: time.Minute},
+			},
+		},
+		{
+			name: "refill capped at burst",
+			rule: passport.RateLimitRule{Requests: 1, Per: time.Second, Burst: 2},
+			takes: []take{
+				{allowed: true},
+				{allowed: true},
+				{after: time.Hour, allowed: true},
+				{allowed: true},
+				{allowed: false, retryAfter: time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			store := NewMemoryRateLimitStore()
+			store.now = func() time.Time { return now }
+
+			for i, take := range tt.takes {
+				now = now.Add(take.after)
+
+				allowed, retryAfter, err := store.Take(context.Background(), "key", tt.rule)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if allowed != take.allowed {
+					t.Fatalf("take %d: expected allowed %v, got %v", i, take.allowed, allowed)
+				}
+
+				if retryAfter != take.retryAfter {
+					t.Fatalf("take %d: expected retry after %v, got %v", i, take.retryAfter, retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStoreKeys(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	rule := passport.RateLimitRule{Requests: 1, Per: time.Minute}
+
+	for _, key := range []string{"ip:10.0.0.1", "ip:10.0.0.2"} {
+		allowed, _, err := store.Take(context.Background(), key, rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !allowed {
+			t.Fatalf("expected first request for %s to be allowed", key)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoRateLimitStore shares buckets between replicas, each take is single atomic update
+type MongoRateLimitStore struct {
+	*passport.MongoRepository
+}
+
+func NewMongoRateLimitStore(client *mongo.Client, conf *passport.Config) *MongoRateLimitStore {
+	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "rate_limits")
+
+	expirationIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresOn", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	_, err := repository.Collection.Indexes().CreateOne(context.TODO(), expirationIndex)
+	if err != nil {
+		panic(err)
+	}
+
+	return &MongoRateLimitStore{repository}
+}
+
+func (s *MongoRateLimitStore) Take(ctx context.Context, key string, rule passport.RateLimitRule) (bool, time.Duration, error) {
+	now := time.Now().UTC()
+	capacity := burst(rule)
+
+	// refill by elapsed time and take token in one pipeline update, so concurrent requests can not overdraw the bucket
+	elapsed := bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updatedOn", now}}}}, 1000}}
+	refilled := bson.M{"$min": bson.A{capacity, bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$tokens", capacity}}, bson.M{"$multiply": bson.A{elapsed, rate(rule)}}}}}}
+	hasToken := bson.M{"$gte": bson.A{"$tokens", 1}}
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"tokens": refilled}}},
+		{{Key: "$set", Value: bson.M{
+			"allowed":   hasToken,
+			"tokens":    bson.M{"$cond": bson.A{hasToken, bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+			"updatedOn": now,
+			"expiresOn": now.Add(time.Duration(capacity / rate(rule) * float64(time.Second))),
+		}}},
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var result struct {
+		Tokens  float64 `bson:"tokens"`
+		Allowed bool    `bson:"allowed"`
+	}
+
+	err := s.Collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&result)
+	if err != nil {
+		return false, 0, err
+	}
+
+	if !result.Allowed {
+		return false, wait(result.Tokens, rule), nil
+	}
+
+	return true, 0, nil
+}
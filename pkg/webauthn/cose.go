@@ -0,0 +1,132 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ugorji/go/codec"
+)
+
+// COSE algorithm identifiers supported for credentials
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// Algorithms lists supported COSE algorithms in order of preference
+var Algorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters from RFC 9052 and RFC 9053
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1
+	coseX   = -2
+	coseY   = -3
+	coseN   = -1
+	coseE   = -2
+
+	ktyOKP = 1
+	ktyEC2 = 2
+	ktyRSA = 3
+
+	crvP256    = 1
+	crvEd25519 = 6
+)
+
+// PublicKey is credential public key together with its COSE algorithm
+type PublicKey struct {
+	Algorithm int64
+	Key       crypto.PublicKey
+}
+
+// ParsePublicKey decodes COSE_Key stored with the credential
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	var params map[int64]interface{}
+
+	err := codec.NewDecoderBytes(data, cborHandle).Decode(&params)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse public key: %w", err)
+	}
+
+	kty, _ := toInt64(params[coseKty])
+	alg, _ := toInt64(params[coseAlg])
+
+	switch {
+	case kty == ktyEC2 && alg == AlgES256:
+		crv, _ := toInt64(params[coseCrv])
+		x, xOk := params[coseX].([]byte)
+		y, yOk := params[coseY].([]byte)
+		if crv != crvP256 || !xOk || !yOk {
+			return nil, errors.New("ec2 public key is malformed")
+		}
+
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("ec2 public key is not on curve")
+		}
+
+		return &PublicKey{Algorithm: alg, Key: key}, nil
+	case kty == ktyOKP && alg == AlgEdDSA:
+		crv, _ := toInt64(params[coseCrv])
+		x, ok := params[coseX].([]byte)
+		if crv != crvEd25519 || !ok || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("okp public key is malformed")
+		}
+
+		return &PublicKey{Algorithm: alg, Key: ed25519.PublicKey(x)}, nil
+	case kty == ktyRSA && alg == AlgRS256:
+		n, nOk := params[coseN].([]byte)
+		e, eOk := params[coseE].([]byte)
+		if !nOk || !eOk {
+			return nil, errors.New("rsa public key is malformed")
+		}
+
+		return &PublicKey{Algorithm: alg, Key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}}, nil
+	}
+
+	return nil, fmt.Errorf("public key type %d with algorithm %d is not supported", kty, alg)
+}
+
+// Verify checks signature over data using the key algorithm
+func (k *PublicKey) Verify(data []byte, signature []byte) error {
+	digest := sha256.Sum256(data)
+
+	switch key := k.Key.(type) {
+	case *ecdsa.PublicKey:
+		if k.Algorithm == AlgES256 && ecdsa.VerifyASN1(key, digest[:], signature) {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if k.Algorithm == AlgEdDSA && ed25519.Verify(key, data, signature) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if k.Algorithm == AlgRS256 && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	}
+
+	return errors.New("signature is invalid")
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), true
+	case int:
+		return int64(v), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,297 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ugorji/go/codec"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	CeremonyCreate = "webauthn.create"
+	CeremonyGet    = "webauthn.get"
+)
+
+// Authenticator data flags
+const (
+	FlagUserPresent            = 0x01
+	FlagUserVerified           = 0x04
+	FlagAttestedCredentialData = 0x40
+	FlagExtensionData          = 0x80
+)
+
+var cborHandle = &codec.CborHandle{}
+
+// Encoding is base64url without padding used for binary values in WebAuthn JSON
+var Encoding = base64.RawURLEncoding
+
+// Expectation holds values relying party expects in a ceremony
+type Expectation struct {
+	Challenge               string
+	RPID                    string
+	Origins                 []string
+	RequireUserVerification bool
+}
+
+// ClientData is the parsed clientDataJSON collected by the browser
+type ClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// AuthenticatorData is the parsed authenticator data of attestation or assertion
+type AuthenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+// Credential is public key credential produced by successful registration
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	AAGUID    []byte
+	SignCount uint32
+	Format    string
+}
+
+type attestationObject struct {
+	Format    string                 `codec:"fmt"`
+	Statement map[string]interface{} `codec:"attStmt"`
+	AuthData  []byte                 `codec:"authData"`
+}
+
+// ParseClientData decodes clientDataJSON collected by the browser
+func ParseClientData(clientDataJSON []byte) (*ClientData, error) {
+	clientData := &ClientData{}
+
+	err := json.Unmarshal(clientDataJSON, clientData)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse client data: %w", err)
+	}
+
+	return clientData, nil
+}
+
+// VerifyRegistration validates attestation response of navigator.credentials.create,
+// supporting none and packed attestation formats
+func VerifyRegistration(attestation []byte, clientDataJSON []byte, expected Expectation) (*Credential, error) {
+	err := verifyClientData(clientDataJSON, CeremonyCreate, expected)
+	if err != nil {
+		return nil, err
+	}
+
+	object := attestationObject{}
+
+	err = codec.NewDecoderBytes(attestation, cborHandle).Decode(&object)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse attestation object: %w", err)
+	}
+
+	authData, err := ParseAuthenticatorData(object.AuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifyAuthenticatorData(authData, expected)
+	if err != nil {
+		return nil, err
+	}
+
+	if authData.Flags&FlagAttestedCredentialData == 0 {
+		return nil, errors.New("attested credential data is missing")
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+
+	switch object.Format {
+	case "none":
+	case "packed":
+		err = verifyPackedStatement(object.Statement, append(append([]byte{}, object.AuthData...), clientDataHash[:]...), authData.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("attestation format %s is not supported", object.Format)
+	}
+
+	return &Credential{
+		ID:        authData.CredentialID,
+		PublicKey: authData.PublicKey,
+		AAGUID:    authData.AAGUID,
+		SignCount: authData.SignCount,
+		Format:    object.Format,
+	}, nil
+}
+
+// VerifyAssertion validates assertion response of navigator.credentials.get against stored credential public key,
+// returning the new signature counter
+func VerifyAssertion(publicKey []byte, authenticatorData []byte, clientDataJSON []byte, signature []byte, expected Expectation) (uint32, error) {
+	err := verifyClientData(clientDataJSON, CeremonyGet, expected)
+	if err != nil {
+		return 0, err
+	}
+
+	authData, err := ParseAuthenticatorData(authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	err = verifyAuthenticatorData(authData, expected)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authenticatorData...), clientDataHash[:]...)
+
+	err = key.Verify(signed, signature)
+	if err != nil {
+		return 0, err
+	}
+
+	return authData.SignCount, nil
+}
+
+// ParseAuthenticatorData decodes authenticator data structure described in WebAuthn section 6.1
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("authenticator data is too short")
+	}
+
+	authData := &AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+
+	if authData.Flags&FlagAttestedCredentialData == 0 {
+		return authData, nil
+	}
+
+	rest := data[37:]
+	if len(rest) < 18 {
+		return nil, errors.New("attested credential data is too short")
+	}
+
+	authData.AAGUID = rest[:16]
+	credentialIDLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+
+	if len(rest) < credentialIDLength {
+		return nil, errors.New("credential id is truncated")
+	}
+
+	authData.CredentialID = rest[:credentialIDLength]
+	rest = rest[credentialIDLength:]
+
+	// public key is followed by extensions when present, so decode just one value to learn its length
+	var publicKey map[int64]interface{}
+	decoder := codec.NewDecoderBytes(rest, cborHandle)
+
+	err := decoder.Decode(&publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse credential public key: %w", err)
+	}
+
+	authData.PublicKey = rest[:decoder.NumBytesRead()]
+
+	return authData, nil
+}
+
+func verifyClientData(clientDataJSON []byte, ceremony string, expected Expectation) error {
+	clientData, err := ParseClientData(clientDataJSON)
+	if err != nil {
+		return err
+	}
+
+	if clientData.Type != ceremony {
+		return fmt.Errorf("client data type %s is not %s", clientData.Type, ceremony)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(clientData.Challenge), []byte(expected.Challenge)) != 1 {
+		return errors.New("challenge does not match")
+	}
+
+	if !slices.Contains(expected.Origins, clientData.Origin) {
+		return fmt.Errorf("origin %s is not allowed", clientData.Origin)
+	}
+
+	return nil
+}
+
+func verifyAuthenticatorData(authData *AuthenticatorData, expected Expectation) error {
+	rpIDHash := sha256.Sum256([]byte(expected.RPID))
+	if !bytes.Equal(authData.RPIDHash, rpIDHash[:]) {
+		return errors.New("relying party id hash does not match")
+	}
+
+	if authData.Flags&FlagUserPresent == 0 {
+		return errors.New("user is not present")
+	}
+
+	if expected.RequireUserVerification && authData.Flags&FlagUserVerified == 0 {
+		return errors.New("user is not verified")
+	}
+
+	return nil
+}
+
+// verifyPackedStatement checks packed attestation signature, made by the attestation certificate
+// or by the credential itself for self attestation. Certificate chains are not validated against roots.
+func verifyPackedStatement(statement map[string]interface{}, signed []byte, credentialPublicKey []byte) error {
+	alg, ok := toInt64(statement["alg"])
+	if !ok {
+		return errors.New("packed attestation algorithm is missing")
+	}
+
+	signature, ok := statement["sig"].([]byte)
+	if !ok {
+		return errors.New("packed attestation signature is missing")
+	}
+
+	chain, ok := statement["x5c"].([]interface{})
+	if !ok || len(chain) == 0 {
+		key, err := ParsePublicKey(credentialPublicKey)
+		if err != nil {
+			return err
+		}
+
+		if key.Algorithm != alg {
+			return errors.New("self attestation algorithm does not match credential")
+		}
+
+		return key.Verify(signed, signature)
+	}
+
+	der, ok := chain[0].([]byte)
+	if !ok {
+		return errors.New("attestation certificate is malformed")
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("could not parse attestation certificate: %w", err)
+	}
+
+	key := &PublicKey{Algorithm: alg, Key: certificate.PublicKey}
+
+	return key.Verify(signed, signature)
+}
@@ -0,0 +1,229 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/ugorji/go/codec"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:3535"
+)
+
+// softwareAuthenticator emulates platform authenticator holding single credential
+type softwareAuthenticator struct {
+	credentialID []byte
+	signer       crypto.Signer
+	algorithm    int64
+	signCount    uint32
+}
+
+func newSoftwareAuthenticator(t *testing.T, algorithm int64) *softwareAuthenticator {
+	var signer crypto.Signer
+	var err error
+
+	switch algorithm {
+	case AlgES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentialID := make([]byte, 16)
+	_, err = rand.Read(credentialID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &softwareAuthenticator{credentialID: credentialID, signer: signer, algorithm: algorithm}
+}
+
+func (a *softwareAuthenticator) publicKey(t *testing.T) []byte {
+	params := map[int64]interface{}{coseAlg: a.algorithm}
+
+	switch key := a.signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		params[coseKty] = ktyEC2
+		params[coseCrv] = crvP256
+		params[coseX] = key.X.FillBytes(make([]byte, 32))
+		params[coseY] = key.Y.FillBytes(make([]byte, 32))
+	case ed25519.PublicKey:
+		params[coseKty] = ktyOKP
+		params[coseCrv] = crvEd25519
+		params[coseX] = []byte(key)
+	}
+
+	return encode(t, params)
+}
+
+func (a *softwareAuthenticator) authenticatorData(t *testing.T, rpID string, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+
+	flags := byte(FlagUserPresent | FlagUserVerified)
+	if attested {
+		flags |= FlagAttestedCredentialData
+	}
+
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.publicKey(t)...)
+	}
+
+	return data
+}
+
+func (a *softwareAuthenticator) sign(t *testing.T, authData []byte, clientDataJSON []byte) []byte {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+
+	var signature []byte
+	var err error
+
+	if a.algorithm == AlgEdDSA {
+		signature, err = a.signer.Sign(rand.Reader, signed, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(signed)
+		signature, err = a.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signature
+}
+
+// create answers navigator.credentials.create with packed self attestation
+func (a *softwareAuthenticator) create(t *testing.T, challenge string, origin string) ([]byte, []byte) {
+	clientDataJSON := clientData(t, CeremonyCreate, challenge, origin)
+	authData := a.authenticatorData(t, testRPID, true)
+
+	attestation := encode(t, map[string]interface{}{
+		"fmt":      "packed",
+		"authData": authData,
+		"attStmt": map[string]interface{}{
+			"alg": a.algorithm,
+			"sig": a.sign(t, authData, clientDataJSON),
+		},
+	})
+
+	return attestation, clientDataJSON
+}
+
+// get answers navigator.credentials.get, incrementing signature counter
+func (a *softwareAuthenticator) get(t *testing.T, challenge string, origin string) ([]byte, []byte, []byte) {
+	a.signCount++
+
+	clientDataJSON := clientData(t, CeremonyGet, challenge, origin)
+	authData := a.authenticatorData(t, testRPID, false)
+
+	return authData, clientDataJSON, a.sign(t, authData, clientDataJSON)
+}
+
+func clientData(t *testing.T, ceremony string, challenge string, origin string) []byte {
+	data, err := json.Marshal(ClientData{Type: ceremony, Challenge: challenge, Origin: origin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func encode(t *testing.T, v interface{}) []byte {
+	var out []byte
+
+	err := codec.NewEncoderBytes(&out, cborHandle).Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return out
+}
+
+func expectation(challenge string) Expectation {
+	return Expectation{Challenge: challenge, RPID: testRPID, Origins: []string{testOrigin}, RequireUserVerification: true}
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	for _, algorithm := range []int64{AlgES256, AlgEdDSA} {
+		authenticator := newSoftwareAuthenticator(t, algorithm)
+
+		attestation, clientDataJSON := authenticator.create(t, "registration-challenge", testOrigin)
+
+		credential, err := VerifyRegistration(attestation, clientDataJSON, expectation("registration-challenge"))
+		if err != nil {
+			t.Fatalf("algorithm %d: registration failed: %v", algorithm, err)
+		}
+
+		if string(credential.ID) != string(authenticator.credentialID) {
+			t.Fatalf("algorithm %d: credential id does not match", algorithm)
+		}
+
+		authData, clientDataJSON, signature := authenticator.get(t, "login-challenge", testOrigin)
+
+		signCount, err := VerifyAssertion(credential.PublicKey, authData, clientDataJSON, signature, expectation("login-challenge"))
+		if err != nil {
+			t.Fatalf("algorithm %d: assertion failed: %v", algorithm, err)
+		}
+
+		if signCount != 1 {
+			t.Fatalf("algorithm %d: expected sign count 1, got %d", algorithm, signCount)
+		}
+	}
+}
+
+func TestRegistrationRejectsWrongChallengeAndOrigin(t *testing.T) {
+	authenticator := newSoftwareAuthenticator(t, AlgES256)
+
+	attestation, clientDataJSON := authenticator.create(t, "registration-challenge", testOrigin)
+
+	_, err := VerifyRegistration(attestation, clientDataJSON, expectation("other-challenge"))
+	if err == nil {
+		t.Fatal("expected challenge mismatch to be rejected")
+	}
+
+	attestation, clientDataJSON = authenticator.create(t, "registration-challenge", "https://evil.example")
+
+	_, err = VerifyRegistration(attestation, clientDataJSON, expectation("registration-challenge"))
+	if err == nil {
+		t.Fatal("expected foreign origin to be rejected")
+	}
+}
+
+func TestAssertionRejectsForeignKeyAndTamperedData(t *testing.T) {
+	authenticator := newSoftwareAuthenticator(t, AlgES256)
+	other := newSoftwareAuthenticator(t, AlgES256)
+
+	authData, clientDataJSON, signature := authenticator.get(t, "login-challenge", testOrigin)
+
+	_, err := VerifyAssertion(other.publicKey(t), authData, clientDataJSON, signature, expectation("login-challenge"))
+	if err == nil {
+		t.Fatal("expected signature of another credential to be rejected")
+	}
+
+	authData[len(authData)-1]++
+
+	_, err = VerifyAssertion(authenticator.publicKey(t), authData, clientDataJSON, signature, expectation("login-challenge"))
+	if err == nil {
+		t.Fatal("expected tampered authenticator data to be rejected")
+	}
+}
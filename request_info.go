@@ -0,0 +1,85 @@
+package passport
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CorrelationHeader carries id that ties together everything done for one request, echoed back in the response
+const CorrelationHeader = "X-Request-ID"
+
+// DeviceHeader carries id the client generated once and keeps for the device, so the device is recognized across browser updates
+const DeviceHeader = "X-Device-ID"
+
+type requestInfoKey struct{}
+
+// RequestInfo describes the caller of the current request for services that record where an action came from
+type RequestInfo struct {
+	IP            string
+	UserAgent     string
+	CorrelationID string
+	DeviceID      string
+	// ActorID and ClientID are known once the request is authenticated
+	ActorID  string
+	ClientID string
+}
+
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFrom returns caller of the request, empty outside of http requests
+func RequestInfoFrom(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
+
+// RequestInfoMiddleware puts caller of the request into request context, keeping correlation id sent by the caller
+func RequestInfoMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		correlationID := c.GetHeader(CorrelationHeader)
+		if !isValidHeaderID(correlationID) {
+			correlationID = newCorrelationID()
+		}
+
+		c.Header(CorrelationHeader, correlationID)
+
+		deviceID := c.GetHeader(DeviceHeader)
+		if !isValidHeaderID(deviceID) {
+			deviceID = ""
+		}
+
+		info := RequestInfo{IP: c.ClientIP(), UserAgent: c.Request.UserAgent(), CorrelationID: correlationID, DeviceID: deviceID}
+		c.Request = c.Request.WithContext(WithRequestInfo(c.Request.Context(), info))
+
+		c.Next()
+	}
+}
+
+// SetRequestActor records who the request is authenticated as
+func SetRequestActor(c *gin.Context, actorID string, clientID string) {
+	info := RequestInfoFrom(c.Request.Context())
+	info.ActorID = actorID
+	info.ClientID = clientID
+
+	c.Request = c.Request.WithContext(WithRequestInfo(c.Request.Context(), info))
+}
+
+func isValidHeaderID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	return strings.IndexFunc(id, func(r rune) bool { return r <= ' ' || r > '~' }) < 0
+}
+
+func newCorrelationID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
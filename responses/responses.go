@@ -1,6 +1,6 @@
 package responses
 
-import "math/big"
+import "time"
 
 type IDResp struct {
 	ID string `json:"id" example:"1"`
@@ -10,9 +10,112 @@ type TokenResponse struct {
 	TokenType    string `json:"tokenType" example:"Bearer"`
 	AccessToken  string `json:"accessToken" example:"token"`
 	RefreshToken string `json:"refreshToken" example:"token"`
+	IDToken      string `json:"idToken,omitempty" example:"token"`
 	ExpiresIn    int64  `json:"expiresIn" example:"1687957803"`
 }
 
+type MfaRequiredResponse struct {
+	Error     string   `json:"error" example:"mfa_required"`
+	MfaToken  string   `json:"mfaToken" example:"token"`
+	Methods   []string `json:"methods"`
+	ExpiresIn int64    `json:"expiresIn" example:"300"`
+}
+
+type TotpEnrollmentResponse struct {
+	Secret     string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	OtpauthURI string `json:"otpauthUri" example:"otpauth://totp/passport:test@test.com?secret=JBSWY3DPEHPK3PXP"`
+}
+
+type RelyingPartyResponse struct {
+	ID   string `json:"id" example:"localhost"`
+	Name string `json:"name" example:"Passport"`
+}
+
+type WebAuthnUserResponse struct {
+	ID          string `json:"id" example:"ZPYlF0v1XH4O7Ouw"`
+	Name        string `json:"name" example:"test"`
+	DisplayName string `json:"displayName" example:"test@test.com"`
+}
+
+type CredentialParameterResponse struct {
+	Type string `json:"type" example:"public-key"`
+	Alg  int64  `json:"alg" example:"-7"`
+}
+
+type CredentialDescriptorResponse struct {
+	Type       string   `json:"type" example:"public-key"`
+	ID         string   `json:"id" example:"AAECAwQFBgcICQoLDA0ODw"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelectionResponse struct {
+	ResidentKey      string `json:"residentKey" example:"preferred"`
+	UserVerification string `json:"userVerification" example:"preferred"`
+}
+
+type CredentialCreationOptionsResponse struct {
+	Challenge              string                         `json:"challenge" example:"Y2hhbGxlbmdl"`
+	RP                     RelyingPartyResponse           `json:"rp"`
+	User                   WebAuthnUserResponse           `json:"user"`
+	PubKeyCredParams       []CredentialParameterResponse  `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout" example:"300000"`
+	ExcludeCredentials     []CredentialDescriptorResponse `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelectionResponse `json:"authenticatorSelection"`
+	Attestation            string                         `json:"attestation" example:"none"`
+}
+
+type CredentialRequestOptionsResponse struct {
+	Challenge        string                         `json:"challenge" example:"Y2hhbGxlbmdl"`
+	RPID             string                         `json:"rpId" example:"localhost"`
+	Timeout          int64                          `json:"timeout" example:"300000"`
+	AllowCredentials []CredentialDescriptorResponse `json:"allowCredentials"`
+	UserVerification string                         `json:"userVerification" example:"preferred"`
+}
+
+type WebAuthnCredentialResponse struct {
+	ID         string     `json:"id" example:"AAECAwQFBgcICQoLDA0ODw"`
+	Name       string     `json:"name" example:"laptop"`
+	CreatedOn  time.Time  `json:"createdOn"`
+	LastUsedOn *time.Time `json:"lastUsedOn,omitempty"`
+}
+
+type SessionResponse struct {
+	ID          string    `json:"id" example:"64e5c5b2a1f0c3a9d4e8b7a1"`
+	ClientID    string    `json:"clientId,omitempty" example:"9f86d081884c7d659a2feaa0c55ad015"`
+	UserAgent   string    `json:"userAgent,omitempty" example:"Mozilla/5.0"`
+	IP          string    `json:"ip,omitempty" example:"203.0.113.7"`
+	AuthMethods []string  `json:"authMethods,omitempty"`
+	CreatedOn   time.Time `json:"createdOn"`
+	LastUsedOn  time.Time `json:"lastUsedOn"`
+	Current     bool      `json:"current"`
+}
+
+type AuditEventResponse struct {
+	ID            string            `json:"id" example:"64e5c5b2a1f0c3a9d4e8b7a1"`
+	CreatedOn     time.Time         `json:"createdOn"`
+	Action        string            `json:"action" example:"user.login"`
+	Outcome       string            `json:"outcome" example:"success"`
+	ActorID       string            `json:"actorId,omitempty"`
+	SubjectID     string            `json:"subjectId,omitempty"`
+	ClientID      string            `json:"clientId,omitempty"`
+	IP            string            `json:"ip,omitempty" example:"203.0.113.7"`
+	UserAgent     string            `json:"userAgent,omitempty" example:"Mozilla/5.0"`
+	CorrelationID string            `json:"correlationId,omitempty"`
+	Reason        string            `json:"reason,omitempty"`
+	Details       map[string]string `json:"details,omitempty"`
+	Sequence      int64             `json:"sequence,omitempty"`
+	Hash          string            `json:"hash,omitempty"`
+}
+
+type AuditEventsResponse struct {
+	Events     []AuditEventResponse `json:"events"`
+	NextCursor string               `json:"nextCursor,omitempty"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
 type ExchangeCodeResponse struct {
 	Code string `json:"code" example:"123456"`
 }
@@ -33,6 +136,103 @@ type UserResponse struct {
 	Rights   []string `json:"rights"`
 }
 
+type IntrospectionResponse struct {
+	Active    bool           `json:"active"`
+	Sub       string         `json:"sub,omitempty"`
+	Username  string         `json:"username,omitempty"`
+	Scope     string         `json:"scope,omitempty"`
+	ClientID  string         `json:"client_id,omitempty"`
+	TokenType string         `json:"token_type,omitempty"`
+	Exp       int64          `json:"exp,omitempty"`
+	Iat       int64          `json:"iat,omitempty"`
+	Jti       string         `json:"jti,omitempty"`
+	Role      string         `json:"role,omitempty"`
+	Rights    []string       `json:"rights,omitempty"`
+	Aud       string         `json:"aud,omitempty"`
+	Act       *ActorResponse `json:"act,omitempty"`
+	AuthTime  int64          `json:"auth_time,omitempty"`
+	Amr       []string       `json:"amr,omitempty"`
+	Acr       string         `json:"acr,omitempty"`
+}
+
+type ActorResponse struct {
+	Sub      string         `json:"sub"`
+	ClientID string         `json:"client_id,omitempty"`
+	Act      *ActorResponse `json:"act,omitempty"`
+}
+
+type UserInfoResponse struct {
+	Sub               string `json:"sub" example:"64e5c5b2a1f0c3a9d4e8b7a1"`
+	Email             string `json:"email" example:"test@test.com"`
+	EmailVerified     bool   `json:"email_verified" example:"true"`
+	PreferredUsername string `json:"preferred_username" example:"test"`
+	UpdatedAt         int64  `json:"updated_at,omitempty" example:"1687957803"`
+}
+
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksURI                           string   `json:"jwks_uri"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint,omitempty"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported,omitempty"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken     string `json:"access_token" example:"token"`
+	TokenType       string `json:"token_type" example:"Bearer"`
+	ExpiresIn       int64  `json:"expires_in" example:"3600"`
+	RefreshToken    string `json:"refresh_token,omitempty" example:"token"`
+	IDToken         string `json:"id_token,omitempty" example:"token"`
+	Scope           string `json:"scope,omitempty" example:"openid email"`
+	IssuedTokenType string `json:"issued_token_type,omitempty" example:"urn:ietf:params:oauth:token-type:access_token"`
+}
+
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code" example:"code"`
+	UserCode                string `json:"user_code" example:"WDJB-MJHT"`
+	VerificationURI         string `json:"verification_uri" example:"http://localhost:3535/device"`
+	VerificationURIComplete string `json:"verification_uri_complete" example:"http://localhost:3535/device?user_code=WDJB-MJHT"`
+	ExpiresIn               int64  `json:"expires_in" example:"600"`
+	Interval                int64  `json:"interval" example:"5"`
+}
+
+type DeviceCodeResponse struct {
+	ClientID   string `json:"clientId" example:"9f86d081884c7d659a2feaa0c55ad015"`
+	ClientName string `json:"clientName" example:"cli"`
+	Scope      string `json:"scope" example:"openid"`
+}
+
+type OAuthErrorResponse struct {
+	Error            string `json:"error" example:"invalid_grant"`
+	ErrorDescription string `json:"error_description,omitempty" example:"Authorization code is invalid"`
+}
+
+type ClientResponse struct {
+	ClientID     string   `json:"clientId" example:"9f86d081884c7d659a2feaa0c55ad015"`
+	Name         string   `json:"name" example:"web"`
+	IsPublic     bool     `json:"isPublic" example:"true"`
+	RedirectURIs []string `json:"redirectUris"`
+	GrantTypes   []string `json:"grantTypes"`
+	Scopes       []string `json:"scopes"`
+	Audiences    []string `json:"audiences,omitempty"`
+}
+
+type CreateClientResponse struct {
+	ClientResponse
+	ClientSecret string `json:"clientSecret,omitempty" example:"secret"`
+}
+
 type RoleResponse struct {
 	Name string `json:"name" example:"admin"`
 }
@@ -46,9 +246,22 @@ type Jwks struct {
 }
 
 type JSONWebKey struct {
-	Kty string   `json:"kty"`
-	Kid string   `json:"kid"`
-	Use string   `json:"use"`
-	N   *big.Int `json:"n"`
-	E   int      `json:"e"`
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// InsufficientAuthenticationResponse asks the client to re-authenticate the user as described in RFC 9470
+type InsufficientAuthenticationResponse struct {
+	Error            string   `json:"error" example:"insufficient_user_authentication"`
+	ErrorDescription string   `json:"error_description,omitempty"`
+	MaxAge           int64    `json:"max_age,omitempty" example:"600"`
+	AcrValues        string   `json:"acr_values,omitempty" example:"aal2"`
+	AmrValues        []string `json:"amr_values,omitempty"`
 }
@@ -1,6 +1,9 @@
 package router
 
 import (
+	"github.com/georgi-georgiev/passport"
+	"github.com/georgi-georgiev/passport/audit"
+	"github.com/georgi-georgiev/passport/clients"
 	"github.com/georgi-georgiev/passport/notifications"
 	"github.com/georgi-georgiev/passport/permissions"
 	"github.com/georgi-georgiev/passport/pkg/middlewares"
@@ -8,27 +11,60 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func Router(app *gin.Engine, userHandlers *users.UserHandlers, permissionHandlers *permissions.PermissionHandlers, middleware *middlewares.IdentityMiddleware, notificationHandlers *notifications.NotificationHandlers) {
+func Router(app *gin.Engine, conf *passport.Config, userHandlers *users.UserHandlers, permissionHandlers *permissions.PermissionHandlers, middleware *middlewares.IdentityMiddleware, limiter *middlewares.RateLimiter, notificationHandlers *notifications.NotificationHandlers, clientHandlers *clients.ClientHandlers, auditHandlers *audit.AuditHandlers) {
 	group := app.Group("")
 	{
 		group.POST("/admins", middleware.Authenticate(), middleware.Authorize("admin"), userHandlers.CreateAdmin)
-		group.POST("/users", userHandlers.CreateUser)
+		group.POST("/users", limiter.Limit("signup", conf.RateLimit.Signup, middlewares.ByIP), userHandlers.CreateUser)
 		group.GET("/users/:userId", middleware.Authenticate(), userHandlers.GetUserById)
 		group.GET("/users", middleware.Authenticate(), middleware.Authorize("admin"), userHandlers.GetUsers)
 		group.PATCH("/users/:userId", middleware.Authenticate(), middleware.Authorize("admin"), userHandlers.UpdateUser)
-		group.DELETE("/users/:userId", middleware.Authenticate(), middleware.Authorize("admin"), userHandlers.DeleteUser)
-		group.POST("/token", userHandlers.GetToken)
+		group.GET("/users/:userId/sessions", middleware.Authenticate(), userHandlers.GetSessions)
+		group.DELETE("/users/:userId/sessions", middleware.Authenticate(), userHandlers.DeleteSessions)
+		group.DELETE("/users/:userId/sessions/:sessionId", middleware.Authenticate(), userHandlers.DeleteSession)
+		group.POST("/users/:userId/unlock", middleware.Authenticate(), middleware.Authorize("admin"), userHandlers.UnlockUser)
+		group.DELETE("/users/:userId", middleware.Authenticate(), middleware.Authorize("admin"), middleware.RequireAuthLevel(conf.Mfa.StepUpMaxAge, passport.AmrOTP, passport.AmrWebAuthn), userHandlers.DeleteUser)
+		group.GET("/authorize", userHandlers.Authorize)
+		group.POST("/authorize", userHandlers.Authorize)
+		group.POST("/token", limiter.Limit("token-ip", conf.RateLimit.Token, middlewares.ByIP), limiter.Limit("token", conf.RateLimit.Token, middlewares.ByClientID), userHandlers.GetToken)
+		group.POST("/revoke", userHandlers.RevokeToken)
+		group.POST("/mfa/totp", middleware.Authenticate(), middleware.RequireAuthLevel(conf.Mfa.StepUpMaxAge), userHandlers.EnrollTotp)
+		group.POST("/mfa/totp/confirm", middleware.Authenticate(), userHandlers.ConfirmTotp)
+		group.DELETE("/mfa/totp", middleware.Authenticate(), userHandlers.DisableTotp)
+		group.POST("/mfa/recovery-codes", middleware.Authenticate(), userHandlers.RegenerateRecoveryCodes)
+		group.POST("/mfa/verify", limiter.Limit("login", conf.RateLimit.Login, middlewares.ByIP), userHandlers.VerifyMfa)
+		group.POST("/passwordless/start", limiter.Limit("mailbox", conf.RateLimit.Mailbox, middlewares.ByEmail), userHandlers.PasswordlessStart)
+		group.GET("/passwordless/verify", limiter.Limit("login", conf.RateLimit.Login, middlewares.ByIP), userHandlers.PasswordlessMagicLink)
+		group.POST("/passwordless/verify", limiter.Limit("login", conf.RateLimit.Login, middlewares.ByIP), userHandlers.PasswordlessVerify)
+		group.POST("/webauthn/register/begin", middleware.Authenticate(), userHandlers.BeginWebAuthnRegistration)
+		group.POST("/webauthn/register/finish", middleware.Authenticate(), middleware.RequireAuthLevel(conf.Mfa.StepUpMaxAge), userHandlers.FinishWebAuthnRegistration)
+		group.POST("/webauthn/login/begin", limiter.Limit("login", conf.RateLimit.Login, middlewares.ByIP), userHandlers.BeginWebAuthnLogin)
+		group.POST("/webauthn/login/finish", limiter.Limit("login", conf.RateLimit.Login, middlewares.ByIP), userHandlers.FinishWebAuthnLogin)
+		group.GET("/webauthn/credentials", middleware.Authenticate(), userHandlers.GetWebAuthnCredentials)
+		group.DELETE("/webauthn/credentials/:credentialId", middleware.Authenticate(), middleware.RequireAuthLevel(conf.Mfa.StepUpMaxAge), userHandlers.DeleteWebAuthnCredential)
+		group.POST("/device/code", limiter.Limit("token-ip", conf.RateLimit.Token, middlewares.ByIP), limiter.Limit("token", conf.RateLimit.Token, middlewares.ByClientID), userHandlers.DeviceAuthorization)
+		group.GET("/device", middleware.Authenticate(), middleware.RequireSessionToken(), userHandlers.GetDeviceCode)
+		group.POST("/device", middleware.Authenticate(), middleware.RequireSessionToken(), userHandlers.VerifyDeviceCode)
+		group.POST("/logout", middleware.Authenticate(), userHandlers.Logout)
+		group.POST("/introspect", middleware.Authenticate(), userHandlers.IntrospectToken)
 		group.GET("/.well-known/jwks.json", userHandlers.JWKS)
-		group.POST("/verify/:token", userHandlers.VerifyEmail)
-		group.POST("/password-recovery/email", userHandlers.PasswordRecovery)
-		group.POST("/password-recovery/exchange", userHandlers.ExchangeRecoveryCode)
-		group.POST("/password-recovery/reset", userHandlers.ResetPassword)
+		group.GET("/.well-known/openid-configuration", userHandlers.OpenIDConfiguration)
+		group.GET("/userinfo", middleware.Authenticate(), userHandlers.UserInfo)
+		group.POST("/userinfo", middleware.Authenticate(), userHandlers.UserInfo)
+		group.POST("/verify/:token", limiter.Limit("verify", conf.RateLimit.Verify, middlewares.ByIP), userHandlers.VerifyEmail)
+		group.POST("/password-recovery/email", limiter.Limit("recovery", conf.RateLimit.Recovery, middlewares.ByIP), limiter.Limit("mailbox", conf.RateLimit.Mailbox, middlewares.ByEmail), userHandlers.PasswordRecovery)
+		group.POST("/password-recovery/exchange", limiter.Limit("recovery", conf.RateLimit.Recovery, middlewares.ByIP), userHandlers.ExchangeRecoveryCode)
+		group.POST("/password-recovery/reset", limiter.Limit("recovery", conf.RateLimit.Recovery, middlewares.ByIP), userHandlers.ResetPassword)
 		group.POST("/roles", middleware.Authenticate(), middleware.Authorize("admin"), permissionHandlers.CreateRole)
 		group.GET("/roles", middleware.Authenticate(), middleware.Authorize("admin"), permissionHandlers.GetRoles)
 		group.PUT("/roles/:roleId", middleware.Authenticate(), middleware.Authorize("admin"), permissionHandlers.UpdateRole)
 		group.POST("/rights", middleware.Authenticate(), middleware.Authorize("admin"), permissionHandlers.CreateRight)
 		group.GET("/rights", middleware.Authenticate(), middleware.Authorize("admin"), permissionHandlers.GetRights)
 		group.PUT("/rights/:rightId", middleware.Authenticate(), middleware.Authorize("admin"), permissionHandlers.UpdateRight)
+		group.POST("/clients", middleware.Authenticate(), middleware.Authorize("admin"), clientHandlers.CreateClient)
+		group.GET("/clients", middleware.Authenticate(), middleware.Authorize("admin"), clientHandlers.GetClients)
+		group.DELETE("/clients/:clientId", middleware.Authenticate(), middleware.Authorize("admin"), clientHandlers.DeleteClient)
+		group.GET("/audit", middleware.Authenticate(), middleware.Authorize("admin"), auditHandlers.GetEvents)
 		group.POST("/facebook/callback", userHandlers.FacebookCallback)
 		group.GET("/notifications", notificationHandlers.Reader)
 	}
@@ -0,0 +1,26 @@
+package passport
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SigningKey struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	CreatedOn  time.Time          `bson:"createdOn"`
+	RetiredOn  *time.Time         `bson:"retiredOn,omitempty"`
+	Kid        string             `bson:"kid"`
+	Algorithm  string             `bson:"algorithm"`
+	PrivateKey string             `bson:"privateKey"`
+}
+
+func NewSigningKey(kid string, algorithm string, privateKey string) *SigningKey {
+	return &SigningKey{
+		ID:         primitive.NewObjectID(),
+		CreatedOn:  time.Now().UTC(),
+		Kid:        kid,
+		Algorithm:  algorithm,
+		PrivateKey: privateKey,
+	}
+}
@@ -0,0 +1,53 @@
+package passport
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of RFC 6238 appendix B, "12345678901234567890" base32 encoded
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTP(t *testing.T) {
+	// codes are the last six digits of the eight digit RFC 6238 test vectors
+	tests := []struct {
+		name    string
+		unix    int64
+		code    string
+		skew    int
+		valid   bool
+		counter int64
+	}{
+		{name: "vector 59", unix: 59, code: "287082", valid: true, counter: 1},
+		{name: "vector 1111111109", unix: 1111111109, code: "081804", valid: true, counter: 37037036},
+		{name: "vector 1111111111", unix: 1111111111, code: "050471", valid: true, counter: 37037037},
+		{name: "vector 1234567890", unix: 1234567890, code: "005924", valid: true, counter: 41152263},
+		{name: "vector 2000000000", unix: 2000000000, code: "279037", valid: true, counter: 66666666},
+		{name: "vector 20000000000", unix: 20000000000, code: "353130", valid: true, counter: 666666666},
+		{name: "wrong code", unix: 59, code: "287083"},
+		{name: "code of previous step without skew", unix: 1111111111, code: "081804"},
+		{name: "code of previous step within skew", unix: 1111111111, code: "081804", skew: 1, valid: true, counter: 37037036},
+		{name: "code of next step within skew", unix: 1111111109, code: "050471", skew: 1, valid: true, counter: 37037037},
+		{name: "empty code", unix: 59, code: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, valid := ValidateTOTP(rfc6238Secret, tt.code, time.Unix(tt.unix, 0), tt.skew)
+			if valid != tt.valid {
+				t.Fatalf("expected valid %v, got %v", tt.valid, valid)
+			}
+
+			if valid && counter != tt.counter {
+				t.Fatalf("expected counter %d, got %d", tt.counter, counter)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPRejectsMalformedSecret(t *testing.T) {
+	_, valid := ValidateTOTP("not base32!", "287082", time.Unix(59, 0), 1)
+	if valid {
+		t.Fatal("expected malformed secret to be rejected")
+	}
+}
@@ -0,0 +1,44 @@
+package users
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuthorizationCode struct {
+	ID                  primitive.ObjectID `bson:"_id"`
+	CreatedOn           time.Time          `bson:"createdOn"`
+	ExpiresOn           time.Time          `bson:"expiresOn"`
+	UsedOn              *time.Time         `bson:"usedOn,omitempty"`
+	CodeHash            string             `bson:"codeHash"`
+	UserID              primitive.ObjectID `bson:"userId"`
+	ClientID            string             `bson:"clientId"`
+	RedirectURI         string             `bson:"redirectUri"`
+	Scope               string             `bson:"scope,omitempty"`
+	Nonce               string             `bson:"nonce,omitempty"`
+	CodeChallenge       string             `bson:"codeChallenge,omitempty"`
+	CodeChallengeMethod string             `bson:"codeChallengeMethod,omitempty"`
+	AuthTime            time.Time          `bson:"authTime"`
+	AuthMethods         []string           `bson:"authMethods,omitempty"`
+}
+
+func NewAuthorizationCode(codeHash string, userID primitive.ObjectID, request AuthorizationRequest, ttl time.Duration) *AuthorizationCode {
+	now := time.Now().UTC()
+
+	return &AuthorizationCode{
+		ID:                  primitive.NewObjectID(),
+		CreatedOn:           now,
+		ExpiresOn:           now.Add(ttl),
+		CodeHash:            codeHash,
+		UserID:              userID,
+		ClientID:            request.ClientID,
+		RedirectURI:         request.RedirectURI,
+		Scope:               request.Scope,
+		Nonce:               request.Nonce,
+		CodeChallenge:       request.CodeChallenge,
+		CodeChallengeMethod: request.CodeChallengeMethod,
+		AuthTime:            request.AuthTime,
+		AuthMethods:         request.AuthMethods,
+	}
+}
@@ -0,0 +1,67 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AuthorizationCodeRepository struct {
+	*passport.MongoRepository
+}
+
+func NewAuthorizationCodeRepository(client *mongo.Client, conf *passport.Config) *AuthorizationCodeRepository {
+	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "authorization_codes")
+
+	codeHashIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "codeHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	expirationIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresOn", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	_, err := repository.Collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{codeHashIndex, expirationIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	return &AuthorizationCodeRepository{repository}
+}
+
+func (r *AuthorizationCodeRepository) GetByHash(ctx context.Context, codeHash string) (*AuthorizationCode, error) {
+	result := &AuthorizationCode{}
+
+	err := r.Collection.FindOne(ctx, bson.M{
+		"codeHash": codeHash,
+	}).Decode(result)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// MarkUsed flags the code as used, returning false when it was already redeemed
+func (r *AuthorizationCodeRepository) MarkUsed(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	filter := bson.M{"_id": id, "usedOn": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"usedOn": time.Now().UTC()}}
+
+	ur, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return ur.ModifiedCount > 0, nil
+}
@@ -0,0 +1,46 @@
+package users
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+	DeviceCodeUsed     = "used"
+)
+
+type DeviceCode struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	CreatedOn      time.Time          `bson:"createdOn"`
+	ExpiresOn      time.Time          `bson:"expiresOn"`
+	LastPolledOn   *time.Time         `bson:"lastPolledOn,omitempty"`
+	DeviceCodeHash string             `bson:"deviceCodeHash"`
+	UserCodeHash   string             `bson:"userCodeHash"`
+	ClientID       string             `bson:"clientId"`
+	Scope          string             `bson:"scope,omitempty"`
+	Interval       int64              `bson:"interval"`
+	Status         string             `bson:"status"`
+	UserID         primitive.ObjectID `bson:"userId,omitempty"`
+	AuthTime       *time.Time         `bson:"authTime,omitempty"`
+	AuthMethods    []string           `bson:"authMethods,omitempty"`
+}
+
+func NewDeviceCode(deviceCodeHash string, userCodeHash string, clientID string, scope string, interval time.Duration, ttl time.Duration) *DeviceCode {
+	now := time.Now().UTC()
+
+	return &DeviceCode{
+		ID:             primitive.NewObjectID(),
+		CreatedOn:      now,
+		ExpiresOn:      now.Add(ttl),
+		DeviceCodeHash: deviceCodeHash,
+		UserCodeHash:   userCodeHash,
+		ClientID:       clientID,
+		Scope:          scope,
+		Interval:       int64(interval.Seconds()),
+		Status:         DeviceCodePending,
+	}
+}
@@ -0,0 +1,94 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DeviceCodeRepository struct {
+	*passport.MongoRepository
+}
+
+func NewDeviceCodeRepository(client *mongo.Client, conf *passport.Config) *DeviceCodeRepository {
+	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "device_codes")
+
+	deviceCodeHashIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "deviceCodeHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	userCodeHashIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "userCodeHash", Value: 1}},
+	}
+
+	expirationIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresOn", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	_, err := repository.Collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{deviceCodeHashIndex, userCodeHashIndex, expirationIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	return &DeviceCodeRepository{repository}
+}
+
+func (r *DeviceCodeRepository) GetByDeviceCodeHash(ctx context.Context, deviceCodeHash string) (*DeviceCode, error) {
+	return r.getOne(ctx, bson.M{"deviceCodeHash": deviceCodeHash})
+}
+
+// GetPendingByUserCodeHash returns device code still waiting for the user decision
+func (r *DeviceCodeRepository) GetPendingByUserCodeHash(ctx context.Context, userCodeHash string) (*DeviceCode, error) {
+	return r.getOne(ctx, bson.M{"userCodeHash": userCodeHash, "status": DeviceCodePending, "expiresOn": bson.M{"$gt": time.Now().UTC()}})
+}
+
+func (r *DeviceCodeRepository) getOne(ctx context.Context, filter bson.M) (*DeviceCode, error) {
+	result := &DeviceCode{}
+
+	err := r.Collection.FindOne(ctx, filter).Decode(result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *DeviceCodeRepository) SetPolled(ctx context.Context, id primitive.ObjectID, interval int64) error {
+	return r.UpdateById(ctx, id, bson.M{"lastPolledOn": time.Now().UTC(), "interval": interval})
+}
+
+// Decide records user decision on a pending device code, returning false when it was already decided
+func (r *DeviceCodeRepository) Decide(ctx context.Context, id primitive.ObjectID, status string, userID primitive.ObjectID, authTime time.Time, authMethods []string) (bool, error) {
+	filter := bson.M{"_id": id, "status": DeviceCodePending}
+	update := bson.M{"$set": bson.M{"status": status, "userId": userID, "authTime": authTime, "authMethods": authMethods}}
+
+	ur, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return ur.ModifiedCount > 0, nil
+}
+
+// MarkUsed flags approved device code as redeemed, returning false when tokens were already issued
+func (r *DeviceCodeRepository) MarkUsed(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	filter := bson.M{"_id": id, "status": DeviceCodeApproved}
+	update := bson.M{"$set": bson.M{"status": DeviceCodeUsed}}
+
+	ur, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return ur.ModifiedCount > 0, nil
+}
@@ -0,0 +1,25 @@
+package users
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// KnownDevice is device the user completed login from, with the networks and countries it was seen in
+type KnownDevice struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	UserID      primitive.ObjectID `bson:"userId"`
+	Fingerprint string             `bson:"fingerprint"`
+	UserAgent   string             `bson:"userAgent,omitempty"`
+	FirstSeenOn time.Time          `bson:"firstSeenOn"`
+	LastSeenOn  time.Time          `bson:"lastSeenOn"`
+	ExpiresOn   time.Time          `bson:"expiresOn"`
+	LastIP      string             `bson:"lastIp,omitempty"`
+	IPRanges    []string           `bson:"ipRanges,omitempty"`
+	Countries   []string           `bson:"countries,omitempty"`
+	// coordinates of the last login, known only with geoip city database
+	LastLatitude   float64 `bson:"lastLatitude,omitempty"`
+	LastLongitude  float64 `bson:"lastLongitude,omitempty"`
+	HasCoordinates bool    `bson:"hasCoordinates"`
+}
@@ -0,0 +1,89 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type KnownDeviceRepository struct {
+	*passport.MongoRepository
+}
+
+func NewKnownDeviceRepository(client *mongo.Client, conf *passport.Config) *KnownDeviceRepository {
+	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "known_devices")
+
+	deviceIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "fingerprint", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	expirationIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresOn", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	_, err := repository.Collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{deviceIndex, expirationIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	return &KnownDeviceRepository{repository}
+}
+
+// GetByUserID lists devices of the user, most recently seen first
+func (r *KnownDeviceRepository) GetByUserID(ctx context.Context, userID primitive.ObjectID) ([]*KnownDevice, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "lastSeenOn", Value: -1}})
+
+	cursor, err := r.Collection.Find(ctx, bson.M{"userId": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*KnownDevice, 0)
+
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Remember adds the device to the user history or refreshes it, recording network and location of the login
+func (r *KnownDeviceRepository) Remember(ctx context.Context, userID primitive.ObjectID, assessment *RiskAssessment, ttl time.Duration) error {
+	location := assessment.Location
+
+	now := time.Now().UTC()
+
+	set := bson.M{
+		"lastSeenOn":     now,
+		"expiresOn":      now.Add(ttl),
+		"lastIp":         assessment.IP,
+		"userAgent":      assessment.UserAgent,
+		"lastLatitude":   location.Latitude,
+		"lastLongitude":  location.Longitude,
+		"hasCoordinates": location.HasCoordinates,
+	}
+
+	addToSet := bson.M{"ipRanges": assessment.IPRange}
+	if location.Country != "" {
+		addToSet["countries"] = location.Country
+	}
+
+	update := bson.M{
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "firstSeenOn": now},
+		"$set":         set,
+		"$addToSet":    addToSet,
+	}
+
+	filter := bson.M{"userId": userID, "fingerprint": assessment.Fingerprint}
+
+	_, err := r.Collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
@@ -0,0 +1,164 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"github.com/georgi-georgiev/passport/facade"
+	"github.com/rotisserie/eris"
+	"go.uber.org/zap"
+)
+
+// LockoutService slows down and eventually blocks password guessing, counters live in mongo so every replica sees them
+type LockoutService struct {
+	notificationFacade *facade.NotificationFacade
+	repository         *LoginAttemptRepository
+	conf               *passport.Config
+	log                *zap.Logger
+}
+
+func NewLockoutService(notificationFacade *facade.NotificationFacade, repository *LoginAttemptRepository, conf *passport.Config, log *zap.Logger) *LockoutService {
+	return &LockoutService{notificationFacade: notificationFacade, repository: repository, conf: conf, log: log}
+}
+
+// Check returns LockedOutError while the account or the address has to wait before next attempt
+func (s *LockoutService) Check(ctx context.Context, username string, ip string) error {
+	now := time.Now().UTC()
+
+	for _, key := range lockoutKeys(username, ip) {
+		attempt, err := s.repository.GetActive(ctx, key)
+		if err != nil {
+			return eris.Wrap(err, "could not get login attempts")
+		}
+
+		if attempt == nil {
+			continue
+		}
+
+		retryAfter := attempt.RetryAfter(now)
+		if retryAfter > 0 {
+			return &LockedOutError{RetryAfter: retryAfter}
+		}
+	}
+
+	return nil
+}
+
+// RegisterFailure counts failed login and delays next attempt exponentially, locking once the threshold is reached.
+// Unknown usernames are counted too so lockout does not reveal which accounts exist.
+func (s *LockoutService) RegisterFailure(ctx context.Context, user *User, username string, ip string) error {
+	account, err := s.register(ctx, accountLockoutKey(username), s.conf.Lockout.MaxAccountFailures)
+	if err != nil {
+		return err
+	}
+
+	if ip != "" {
+		_, err = s.register(ctx, ipLockoutKey(ip), s.conf.Lockout.MaxIPFailures)
+		if err != nil {
+			return err
+		}
+	}
+
+	if account.LockedUntil != nil && account.Failures == s.conf.Lockout.MaxAccountFailures {
+		s.log.Warn("account locked", zap.String("username", username), zap.String("ip", ip), zap.Time("lockedUntil", *account.LockedUntil))
+
+		if user != nil {
+			s.notifyLocked(ctx, user, ip)
+		}
+	}
+
+	return nil
+}
+
+// RegisterSuccess clears failures of the account, address counter keeps decaying on its own
+func (s *LockoutService) RegisterSuccess(ctx context.Context, username string) error {
+	err := s.repository.DeleteByKey(ctx, accountLockoutKey(username))
+	if err != nil {
+		return eris.Wrap(err, "could not reset login attempts")
+	}
+
+	return nil
+}
+
+// Unlock lifts lockout of the user before it expires
+func (s *LockoutService) Unlock(ctx context.Context, user *User) error {
+	err := s.repository.DeleteByKey(ctx, accountLockoutKey(user.Username))
+	if err != nil {
+		return eris.Wrap(err, "could not unlock user")
+	}
+
+	s.log.Info("account unlocked", zap.String("userId", user.ID.Hex()))
+
+	return nil
+}
+
+func (s *LockoutService) register(ctx context.Context, key string, maxFailures int) (*LoginAttempt, error) {
+	attempt, err := s.repository.Increment(ctx, key, s.conf.Lockout.Window)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not count login attempt")
+	}
+
+	nextAttemptOn := s.penalize(attempt, maxFailures, time.Now().UTC())
+
+	err = s.repository.Block(ctx, attempt.ID, nextAttemptOn, attempt.LockedUntil)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not delay login attempt")
+	}
+
+	return attempt, nil
+}
+
+// penalize locks the attempt once failures reach max failures and returns when the next attempt is allowed
+func (s *LockoutService) penalize(attempt *LoginAttempt, maxFailures int, now time.Time) time.Time {
+	if attempt.Failures >= maxFailures {
+		lockedUntil := now.Add(s.conf.Lockout.Duration)
+		attempt.LockedUntil = &lockedUntil
+	}
+
+	return now.Add(s.delay(attempt.Failures))
+}
+
+// delay doubles with every failure starting at base delay, capped at max delay
+func (s *LockoutService) delay(failures int) time.Duration {
+	delay := s.conf.Lockout.BaseDelay
+	for i := 1; i < failures && delay < s.conf.Lockout.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > s.conf.Lockout.MaxDelay {
+		delay = s.conf.Lockout.MaxDelay
+	}
+
+	return delay
+}
+
+func (s *LockoutService) notifyLocked(ctx context.Context, user *User, ip string) {
+	s.notificationFacade.Publish(ctx, "email", facade.Message{
+		Topic:     "identity",
+		Header:    "Account Locked",
+		Body:      fmt.Sprintf("Your account was locked for %s after %d failed sign in attempts from %s. If this was not you, reset your password.", s.conf.Lockout.Duration, s.conf.Lockout.MaxAccountFailures, ip),
+		Params:    map[string]string{"email": user.Email},
+		Meta:      nil,
+		Timestamp: time.Now().Unix(),
+	}, user.ID.Hex())
+}
+
+func lockoutKeys(username string, ip string) []string {
+	if ip == "" {
+		return []string{accountLockoutKey(username)}
+	}
+
+	return []string{accountLockoutKey(username), ipLockoutKey(ip)}
+}
+
+// accountLockoutKey lowercases username the same way user lookup does, so changing case does not start fresh counter
+func accountLockoutKey(username string) string {
+	return "account:" + strings.ToLower(username)
+}
+
+func ipLockoutKey(ip string) string {
+	return "ip:" + ip
+}
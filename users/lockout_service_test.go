@@ -0,0 +1,65 @@
+package users
+
+import (
+	"testing"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+)
+
+func TestLockoutPenalize(t *testing.T) {
+	service := &LockoutService{conf: &passport.Config{Lockout: passport.LockoutConfiguration{
+		MaxAccountFailures: 5,
+		Duration:           15 * time.Minute,
+		BaseDelay:          time.Second,
+		MaxDelay:           5 * time.Second,
+	}}}
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		failures   int
+		retryAfter time.Duration
+		isLocked   bool
+	}{
+		{failures: 1, retryAfter: time.Second},
+		{failures: 2, retryAfter: 2 * time.Second},
+		{failures: 3, retryAfter: 4 * time.Second},
+		{failures: 4, retryAfter: 5 * time.Second},
+		{failures: 5, retryAfter: 15 * time.Minute, isLocked: true},
+		{failures: 6, retryAfter: 15 * time.Minute, isLocked: true},
+	}
+
+	for _, tt := range tests {
+		attempt := &LoginAttempt{Failures: tt.failures}
+		attempt.NextAttemptOn = service.penalize(attempt, 5, now)
+
+		if (attempt.LockedUntil != nil) != tt.isLocked {
+			t.Fatalf("failure %d: expected locked %v, got %v", tt.failures, tt.isLocked, attempt.LockedUntil)
+		}
+
+		retryAfter := attempt.RetryAfter(now)
+		if retryAfter != tt.retryAfter {
+			t.Fatalf("failure %d: expected retry after %v, got %v", tt.failures, tt.retryAfter, retryAfter)
+		}
+
+		if attempt.RetryAfter(now.Add(tt.retryAfter)) != 0 {
+			t.Fatalf("failure %d: expected next attempt to be allowed after %v", tt.failures, tt.retryAfter)
+		}
+	}
+}
+
+func TestAccountLockoutKey(t *testing.T) {
+	if accountLockoutKey("Alice") != accountLockoutKey("alice") {
+		t.Fatal("expected username case not to start a fresh counter")
+	}
+
+	keys := lockoutKeys("alice", "10.0.0.1")
+	if len(keys) != 2 || keys[0] != "account:alice" || keys[1] != "ip:10.0.0.1" {
+		t.Fatalf("unexpected lockout keys %v", keys)
+	}
+
+	if keys := lockoutKeys("alice", ""); len(keys) != 1 {
+		t.Fatalf("expected only account key without address, got %v", keys)
+	}
+}
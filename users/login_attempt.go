@@ -0,0 +1,42 @@
+package users
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginAttempt counts consecutive failed logins for an account or client address
+type LoginAttempt struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Key            string             `bson:"key"`
+	Failures       int                `bson:"failures"`
+	FirstFailureOn time.Time          `bson:"firstFailureOn"`
+	LastFailureOn  time.Time          `bson:"lastFailureOn"`
+	NextAttemptOn  time.Time          `bson:"nextAttemptOn"`
+	LockedUntil    *time.Time         `bson:"lockedUntil,omitempty"`
+	ExpiresOn      time.Time          `bson:"expiresOn"`
+}
+
+// RetryAfter returns how long login stays blocked, zero when next attempt is allowed
+func (a *LoginAttempt) RetryAfter(now time.Time) time.Duration {
+	blockedUntil := a.NextAttemptOn
+	if a.LockedUntil != nil && a.LockedUntil.After(blockedUntil) {
+		blockedUntil = *a.LockedUntil
+	}
+
+	if !blockedUntil.After(now) {
+		return 0
+	}
+
+	return blockedUntil.Sub(now)
+}
+
+// LockedOutError is returned instead of checking the password while login is delayed or locked
+type LockedOutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedOutError) Error() string {
+	return "Too many failed login attempts, try again later"
+}
@@ -0,0 +1,99 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LoginAttemptRepository struct {
+	*passport.MongoRepository
+}
+
+func NewLoginAttemptRepository(client *mongo.Client, conf *passport.Config) *LoginAttemptRepository {
+	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "login_attempts")
+
+	keyIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	expirationIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresOn", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	_, err := repository.Collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{keyIndex, expirationIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	return &LoginAttemptRepository{repository}
+}
+
+// GetActive returns counter for the key unless it already expired, ttl monitor removes expired ones only once a minute
+func (r *LoginAttemptRepository) GetActive(ctx context.Context, key string) (*LoginAttempt, error) {
+	result := &LoginAttempt{}
+
+	err := r.Collection.FindOne(ctx, bson.M{"key": key, "expiresOn": bson.M{"$gt": time.Now().UTC()}}).Decode(result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Increment counts failure for the key, starting new counter when previous one expired
+func (r *LoginAttemptRepository) Increment(ctx context.Context, key string, window time.Duration) (*LoginAttempt, error) {
+	now := time.Now().UTC()
+
+	_, err := r.Collection.DeleteOne(ctx, bson.M{"key": key, "expiresOn": bson.M{"$lte": now}})
+	if err != nil {
+		return nil, err
+	}
+
+	update := bson.M{
+		"$inc":         bson.M{"failures": 1},
+		"$set":         bson.M{"lastFailureOn": now},
+		"$max":         bson.M{"expiresOn": now.Add(window)},
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "firstFailureOn": now, "nextAttemptOn": now},
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	result := &LoginAttempt{}
+
+	err = r.Collection.FindOneAndUpdate(ctx, bson.M{"key": key}, update, opts).Decode(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Block delays next attempt and optionally locks the key, keeping the counter at least as long as the block lasts
+func (r *LoginAttemptRepository) Block(ctx context.Context, id primitive.ObjectID, nextAttemptOn time.Time, lockedUntil *time.Time) error {
+	set := bson.M{"nextAttemptOn": nextAttemptOn}
+	expiresOn := nextAttemptOn
+
+	if lockedUntil != nil {
+		set["lockedUntil"] = *lockedUntil
+		expiresOn = *lockedUntil
+	}
+
+	_, err := r.Collection.UpdateByID(ctx, id, bson.M{"$set": set, "$max": bson.M{"expiresOn": expiresOn}})
+	return err
+}
+
+func (r *LoginAttemptRepository) DeleteByKey(ctx context.Context, key string) error {
+	_, err := r.Collection.DeleteOne(ctx, bson.M{"key": key})
+	return err
+}
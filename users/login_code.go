@@ -0,0 +1,35 @@
+package users
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginCode is passwordless login sent by email, usable either as one-time code or as magic link
+type LoginCode struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	CreatedOn time.Time          `bson:"createdOn"`
+	ExpiresOn time.Time          `bson:"expiresOn"`
+	UsedOn    *time.Time         `bson:"usedOn,omitempty"`
+	RevokedOn *time.Time         `bson:"revokedOn,omitempty"`
+	UserID    primitive.ObjectID `bson:"userId"`
+	CodeHash  string             `bson:"codeHash"`
+	LinkHash  string             `bson:"linkHash"`
+	Attempts  int                `bson:"attempts"`
+	Scope     string             `bson:"scope,omitempty"`
+}
+
+func NewLoginCode(userID primitive.ObjectID, codeHash string, linkHash string, scope string, ttl time.Duration) *LoginCode {
+	now := time.Now().UTC()
+
+	return &LoginCode{
+		ID:        primitive.NewObjectID(),
+		CreatedOn: now,
+		ExpiresOn: now.Add(ttl),
+		UserID:    userID,
+		CodeHash:  codeHash,
+		LinkHash:  linkHash,
+		Scope:     scope,
+	}
+}
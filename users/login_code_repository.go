@@ -0,0 +1,115 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LoginCodeRepository struct {
+	*passport.MongoRepository
+}
+
+func NewLoginCodeRepository(client *mongo.Client, conf *passport.Config) *LoginCodeRepository {
+	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "login_codes")
+
+	linkHashIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "linkHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	userIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdOn", Value: -1}},
+	}
+
+	expirationIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "createdOn", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(conf.Passwordless.CodeTTL.Seconds())),
+	}
+
+	_, err := repository.Collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{linkHashIndex, userIndex, expirationIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	return &LoginCodeRepository{repository}
+}
+
+// RevokeActive invalidates codes the user has not used yet, so only the latest one works
+func (r *LoginCodeRepository) RevokeActive(ctx context.Context, userID primitive.ObjectID) error {
+	filter := bson.M{"userId": userID, "usedOn": bson.M{"$exists": false}, "revokedOn": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revokedOn": time.Now().UTC()}}
+
+	_, err := r.Collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (r *LoginCodeRepository) GetActiveByLinkHash(ctx context.Context, linkHash string) (*LoginCode, error) {
+	return r.getActive(ctx, bson.M{"linkHash": linkHash})
+}
+
+func (r *LoginCodeRepository) getActive(ctx context.Context, filter bson.M) (*LoginCode, error) {
+	filter["usedOn"] = bson.M{"$exists": false}
+	filter["revokedOn"] = bson.M{"$exists": false}
+	filter["expiresOn"] = bson.M{"$gt": time.Now().UTC()}
+
+	result := &LoginCode{}
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "createdOn", Value: -1}})
+
+	err := r.Collection.FindOne(ctx, filter, opts).Decode(result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ReserveAttempt counts attempt on active code of the user before the code is checked, returning nil once max
+// attempts were used. Counting and checking in a single update keeps parallel guesses from getting past the limit.
+func (r *LoginCodeRepository) ReserveAttempt(ctx context.Context, userID primitive.ObjectID, maxAttempts int) (*LoginCode, error) {
+	filter := bson.M{
+		"userId":    userID,
+		"usedOn":    bson.M{"$exists": false},
+		"revokedOn": bson.M{"$exists": false},
+		"expiresOn": bson.M{"$gt": time.Now().UTC()},
+		"attempts":  bson.M{"$lt": maxAttempts},
+	}
+
+	update := bson.M{"$inc": bson.M{"attempts": 1}}
+
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "createdOn", Value: -1}}).SetReturnDocument(options.After)
+
+	result := &LoginCode{}
+
+	err := r.Collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// MarkUsed flags the code as redeemed, returning false when it was already used or revoked
+func (r *LoginCodeRepository) MarkUsed(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	filter := bson.M{"_id": id, "usedOn": bson.M{"$exists": false}, "revokedOn": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"usedOn": time.Now().UTC()}}
+
+	ur, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return ur.ModifiedCount > 0, nil
+}
@@ -0,0 +1,68 @@
+package users
+
+import (
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MfaChallenge is issued after successful password check while second factor is still pending
+type MfaChallenge struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	CreatedOn time.Time          `bson:"createdOn"`
+	ExpiresOn time.Time          `bson:"expiresOn"`
+	UsedOn    *time.Time         `bson:"usedOn,omitempty"`
+	UserID    primitive.ObjectID `bson:"userId"`
+	TokenHash string             `bson:"tokenHash"`
+	Attempts  int                `bson:"attempts"`
+	ClientID  string             `bson:"clientId,omitempty"`
+	Scope     string             `bson:"scope,omitempty"`
+	Nonce     string             `bson:"nonce,omitempty"`
+	// methods of the first factor, second factor is appended on verification
+	AuthMethods []string `bson:"authMethods,omitempty"`
+	// EmailCodeHash is set when one-time code was emailed because the login looked risky
+	EmailCodeHash string `bson:"emailCodeHash,omitempty"`
+}
+
+func NewMfaChallenge(userID primitive.ObjectID, tokenHash string, request TokenRequest, ttl time.Duration) *MfaChallenge {
+	now := time.Now().UTC()
+
+	return &MfaChallenge{
+		ID:          primitive.NewObjectID(),
+		CreatedOn:   now,
+		ExpiresOn:   now.Add(ttl),
+		UserID:      userID,
+		TokenHash:   tokenHash,
+		ClientID:    request.ClientID,
+		Scope:       request.Scope,
+		Nonce:       request.Nonce,
+		AuthMethods: request.AuthMethods,
+	}
+}
+
+// TokenRequest restores the request the challenge was issued for, authenticated now with both factors
+func (c *MfaChallenge) TokenRequest(secondFactor ...string) TokenRequest {
+	request := TokenRequest{ClientID: c.ClientID, Scope: c.Scope, Nonce: c.Nonce}
+
+	methods := append(append([]string{}, c.AuthMethods...), secondFactor...)
+
+	return request.Authenticated(append(methods, passport.AmrMfa)...)
+}
+
+const (
+	MfaMethodTotp         = "totp"
+	MfaMethodRecoveryCode = "recovery_code"
+	MfaMethodEmailOtp     = "email_otp"
+)
+
+// MfaRequiredError is returned instead of tokens when the user has to complete second factor
+type MfaRequiredError struct {
+	MfaToken  string
+	Methods   []string
+	ExpiresIn int64
+}
+
+func (e *MfaRequiredError) Error() string {
+	return "Second factor is required"
+}
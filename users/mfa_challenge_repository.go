@@ -0,0 +1,84 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MfaChallengeRepository struct {
+	*passport.MongoRepository
+}
+
+func NewMfaChallengeRepository(client *mongo.Client, conf *passport.Config) *MfaChallengeRepository {
+	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "mfa_challenges")
+
+	tokenHashIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	expirationIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresOn", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	_, err := repository.Collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{tokenHashIndex, expirationIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	return &MfaChallengeRepository{repository}
+}
+
+// GetActiveByHash returns challenge that is neither used nor expired
+func (r *MfaChallengeRepository) GetActiveByHash(ctx context.Context, tokenHash string) (*MfaChallenge, error) {
+	result := &MfaChallenge{}
+
+	err := r.Collection.FindOne(ctx, bson.M{
+		"tokenHash": tokenHash,
+		"usedOn":    bson.M{"$exists": false},
+		"expiresOn": bson.M{"$gt": time.Now().UTC()},
+	}).Decode(result)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ReserveAttempt counts attempt before the code is checked, returning false once max attempts were used.
+// Counting and checking in a single update keeps parallel guesses from getting past the limit.
+func (r *MfaChallengeRepository) ReserveAttempt(ctx context.Context, id primitive.ObjectID, maxAttempts int) (bool, error) {
+	filter := bson.M{"_id": id, "usedOn": bson.M{"$exists": false}, "attempts": bson.M{"$lt": maxAttempts}}
+	update := bson.M{"$inc": bson.M{"attempts": 1}}
+
+	ur, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return ur.ModifiedCount > 0, nil
+}
+
+// MarkUsed flags the challenge as completed, returning false when it was already used
+func (r *MfaChallengeRepository) MarkUsed(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	filter := bson.M{"_id": id, "usedOn": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"usedOn": time.Now().UTC()}}
+
+	ur, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return ur.ModifiedCount > 0, nil
+}
@@ -0,0 +1,230 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/georgi-georgiev/blunder"
+	"github.com/georgi-georgiev/passport/payloads"
+	"github.com/georgi-georgiev/passport/responses"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EnrollTotpHandler godoc
+// @Summary Enroll TOTP
+// @Description Generates TOTP secret and otpauth uri for the QR code, second factor is enabled after confirmation
+// @Tags identity
+// @Produce  json
+// @Security OAuth2Application
+// @Success 200 {object} responses.TotpEnrollmentResponse
+// @Failure      400  {object}  blunder.HTTPErrorResponse
+// @Router /mfa/totp [post]
+func (h *UserHandlers) EnrollTotp(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	secret, uri, err := h.mfaService.EnrollTotp(c.Request.Context(), user)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, responses.TotpEnrollmentResponse{Secret: secret, OtpauthURI: uri})
+}
+
+// ConfirmTotpHandler godoc
+// @Summary Confirm TOTP
+// @Description Enables TOTP second factor with code from the authenticator app and returns recovery codes
+// @Tags identity
+// @Accept  json
+// @Produce  json
+// @Security OAuth2Application
+// @Param payload body payloads.TotpCodePayload true "payload"
+// @Success 200 {object} responses.RecoveryCodesResponse
+// @Failure      400  {object}  blunder.HTTPErrorResponse
+// @Router /mfa/totp/confirm [post]
+func (h *UserHandlers) ConfirmTotp(c *gin.Context) {
+	var payload payloads.TotpCodePayload
+	errors := h.blunder.BindJson(c.Request, &payload)
+	if errors != nil {
+		for _, err := range errors {
+			h.blunder.GinAdd(c, err)
+		}
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.mfaService.ConfirmTotp(c.Request.Context(), user, payload.Code)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, responses.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodesHandler godoc
+// @Summary Regenerate recovery codes
+// @Description Replaces all mfa recovery codes, current code from the authenticator app is required
+// @Tags identity
+// @Accept  json
+// @Produce  json
+// @Security OAuth2Application
+// @Param payload body payloads.TotpCodePayload true "payload"
+// @Success 200 {object} responses.RecoveryCodesResponse
+// @Failure      400  {object}  blunder.HTTPErrorResponse
+// @Failure      429  {object}  blunder.HTTPErrorResponse
+// @Router /mfa/recovery-codes [post]
+func (h *UserHandlers) RegenerateRecoveryCodes(c *gin.Context) {
+	var payload payloads.TotpCodePayload
+	errors := h.blunder.BindJson(c.Request, &payload)
+	if errors != nil {
+		for _, err := range errors {
+			h.blunder.GinAdd(c, err)
+		}
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(c.Request.Context(), user, payload.Code)
+	if h.lockedOut(c, err) {
+		return
+	}
+
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, responses.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTotpHandler godoc
+// @Summary Disable TOTP
+// @Description Disables TOTP second factor, current code from the authenticator app is required
+// @Tags identity
+// @Accept  json
+// @Security OAuth2Application
+// @Param payload body payloads.TotpCodePayload true "payload"
+// @Success 204
+// @Failure      400  {object}  blunder.HTTPErrorResponse
+// @Failure      429  {object}  blunder.HTTPErrorResponse
+// @Router /mfa/totp [delete]
+func (h *UserHandlers) DisableTotp(c *gin.Context) {
+	var payload payloads.TotpCodePayload
+	errors := h.blunder.BindJson(c.Request, &payload)
+	if errors != nil {
+		for _, err := range errors {
+			h.blunder.GinAdd(c, err)
+		}
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	err := h.mfaService.DisableTotp(c.Request.Context(), user, payload.Code)
+	if h.lockedOut(c, err) {
+		return
+	}
+
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// VerifyMfaHandler godoc
+// @Summary Verify second factor
+// @Description Exchanges mfa token returned by the token endpoint and second factor code for tokens, method is totp, recovery_code or email_otp when the code was emailed for a risky login
+// @Tags identity
+// @Accept  json
+// @Produce  json
+// @Param payload body payloads.VerifyMfaPayload true "payload"
+// @Success 200 {object} responses.TokenResponse
+// @Failure      400  {object}  blunder.HTTPErrorResponse
+// @Failure      429  {object}  blunder.HTTPErrorResponse
+// @Router /mfa/verify [post]
+func (h *UserHandlers) VerifyMfa(c *gin.Context) {
+	var payload payloads.VerifyMfaPayload
+	errors := h.blunder.BindJson(c.Request, &payload)
+	if errors != nil {
+		for _, err := range errors {
+			h.blunder.GinAdd(c, err)
+		}
+		return
+	}
+
+	tokens, err := h.mfaService.VerifyChallenge(c.Request.Context(), payload.MfaToken, payload.Method, payload.Code)
+	if h.lockedOut(c, err) {
+		return
+	}
+
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, responses.TokenResponse{TokenType: "Bearer", AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken, IDToken: tokens.IDToken, ExpiresIn: tokens.ExpiresIn})
+}
+
+// mfaRequired writes challenge response when password was accepted but second factor is pending
+func (h *UserHandlers) mfaRequired(c *gin.Context, err error) bool {
+	var mfaErr *MfaRequiredError
+	if !errors.As(err, &mfaErr) {
+		return false
+	}
+
+	c.JSON(http.StatusForbidden, responses.MfaRequiredResponse{Error: "mfa_required", MfaToken: mfaErr.MfaToken, Methods: mfaErr.Methods, ExpiresIn: mfaErr.ExpiresIn})
+	return true
+}
+
+// currentUser loads the authenticated user, writing unauthorized response when it is missing
+func (h *UserHandlers) currentUser(c *gin.Context) (*User, bool) {
+	userId, err := primitive.ObjectIDFromHex(c.GetString("userId"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, blunder.Unauthorized())
+		return nil, false
+	}
+
+	user, err := h.userService.GetById(c.Request.Context(), userId)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return nil, false
+	}
+
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, blunder.Unauthorized())
+		return nil, false
+	}
+
+	return user, true
+}
+
+// session returns authentication of the bearer token set by the identity middleware
+func (h *UserHandlers) session(c *gin.Context) TokenRequest {
+	request := TokenRequest{AuthMethods: c.GetStringSlice("amr")}
+
+	// tokens without auth_time leave it zero instead of claiming authentication in 1970
+	if authTime := c.GetInt64("authTime"); authTime != 0 {
+		request.AuthTime = time.Unix(authTime, 0).UTC()
+	}
+
+	return request
+}
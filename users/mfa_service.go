@@ -0,0 +1,342 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"github.com/georgi-georgiev/passport/facade"
+	"github.com/rotisserie/eris"
+	"go.uber.org/zap"
+)
+
+type MfaService struct {
+	notificationFacade     *facade.NotificationFacade
+	userService            *UserService
+	repository             *UserRepository
+	mfaChallengeRepository *MfaChallengeRepository
+	lockoutService         *LockoutService
+	conf                   *passport.Config
+	log                    *zap.Logger
+}
+
+func NewMfaService(notificationFacade *facade.NotificationFacade, userService *UserService, repository *UserRepository, mfaChallengeRepository *MfaChallengeRepository, lockoutService *LockoutService, conf *passport.Config, log *zap.Logger) *MfaService {
+	return &MfaService{notificationFacade: notificationFacade, userService: userService, repository: repository, mfaChallengeRepository: mfaChallengeRepository, lockoutService: lockoutService, conf: conf, log: log}
+}
+
+// EnrollTotp generates new secret for the user, returning it with otpauth uri for authenticator apps
+func (s *MfaService) EnrollTotp(ctx context.Context, user *User) (string, string, error) {
+	if user.IsTotpEnabled {
+		return "", "", eris.New("Two-factor authentication is already enabled")
+	}
+
+	secret, err := passport.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", eris.Wrap(err, "could not generate totp secret")
+	}
+
+	encryptedSecret, err := passport.Encrypt(s.conf.Mfa.EncryptionKey, secret, nil)
+	if err != nil {
+		return "", "", eris.Wrap(err, "could not encrypt totp secret")
+	}
+
+	err = s.repository.SetTotpSecret(ctx, user.ID, encryptedSecret)
+	if err != nil {
+		return "", "", eris.Wrap(err, "could not store totp secret")
+	}
+
+	return secret, passport.TOTPURI(s.conf.App.Name, user.Email, secret), nil
+}
+
+// ConfirmTotp enables second factor once the user proves the authenticator app is set up,
+// returning recovery codes to be kept in case the authenticator is lost
+func (s *MfaService) ConfirmTotp(ctx context.Context, user *User, code string) ([]string, error) {
+	if user.IsTotpEnabled {
+		return nil, eris.New("Two-factor authentication is already enabled")
+	}
+
+	if user.TotpSecret == "" {
+		return nil, eris.New("Two-factor enrollment has not been started")
+	}
+
+	counter, ok, err := s.validateTotp(user, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, eris.New("Code is invalid")
+	}
+
+	err = s.repository.EnableTotp(ctx, user.ID, counter)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not enable totp")
+	}
+
+	return s.generateRecoveryCodes(ctx, user)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, requiring current code from the authenticator app
+func (s *MfaService) RegenerateRecoveryCodes(ctx context.Context, user *User, code string) ([]string, error) {
+	if !user.IsTotpEnabled {
+		return nil, eris.New("Two-factor authentication is not enabled")
+	}
+
+	err := s.verifyTotp(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.generateRecoveryCodes(ctx, user)
+}
+
+// DisableTotp turns second factor off, requiring current code from the authenticator app
+func (s *MfaService) DisableTotp(ctx context.Context, user *User, code string) error {
+	if !user.IsTotpEnabled {
+		return eris.New("Two-factor authentication is not enabled")
+	}
+
+	err := s.verifyTotp(ctx, user, code)
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.DisableTotp(ctx, user.ID)
+	if err != nil {
+		return eris.Wrap(err, "could not disable totp")
+	}
+
+	return nil
+}
+
+// VerifyChallenge exchanges mfa token returned by the token endpoint together with second factor code for tokens,
+// the code is from the authenticator app, one of the recovery codes or emailed for risky login
+func (s *MfaService) VerifyChallenge(ctx context.Context, mfaToken string, method string, code string) (*Tokens, error) {
+	challenge, err := s.mfaChallengeRepository.GetActiveByHash(ctx, passport.HashToken(mfaToken))
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get mfa challenge")
+	}
+
+	if challenge == nil {
+		return nil, eris.New("Mfa token is invalid or expired")
+	}
+
+	user, err := s.userService.GetById(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || !user.IsActive {
+		return nil, eris.New("Mfa token is invalid or expired")
+	}
+
+	ip := passport.RequestInfoFrom(ctx).IP
+
+	err = s.lockoutService.Check(ctx, user.Username, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	isReserved, err := s.mfaChallengeRepository.ReserveAttempt(ctx, challenge.ID, s.conf.Mfa.MaxAttempts)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not count mfa attempt")
+	}
+
+	if !isReserved {
+		return nil, eris.New("Too many attempts, login again")
+	}
+
+	// challenge without totp asks for the emailed code
+	if method == "" && challenge.EmailCodeHash != "" {
+		method = MfaMethodEmailOtp
+	}
+
+	var ok bool
+
+	// recovery codes are fallback of the second factor and do not count as otp for step-up
+	var amr []string
+
+	switch method {
+	case "", MfaMethodTotp:
+		if user.IsTotpEnabled {
+			ok, err = s.useTotp(ctx, user, code)
+		}
+		amr = []string{passport.AmrOTP}
+	case MfaMethodRecoveryCode:
+		ok, err = s.useRecoveryCode(ctx, user, code)
+	case MfaMethodEmailOtp:
+		ok = challenge.EmailCodeHash != "" && passport.Match(code, challenge.EmailCodeHash)
+		amr = []string{passport.AmrOTP}
+	default:
+		return nil, eris.Errorf("Mfa method %s is not supported", method)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		s.registerFailure(ctx, user, ip)
+		return nil, eris.New("Code is invalid")
+	}
+
+	isMarked, err := s.mfaChallengeRepository.MarkUsed(ctx, challenge.ID)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not mark mfa challenge as used")
+	}
+
+	if !isMarked {
+		return nil, eris.New("Mfa token is invalid or expired")
+	}
+
+	return s.userService.IssueTokens(ctx, user, challenge.TokenRequest(amr...))
+}
+
+// verifyTotp checks code from the authenticator app for account changes, wrong codes count towards lockout
+// the same way wrong passwords do so the code cannot be guessed with a stolen session
+func (s *MfaService) verifyTotp(ctx context.Context, user *User, code string) error {
+	ip := passport.RequestInfoFrom(ctx).IP
+
+	err := s.lockoutService.Check(ctx, user.Username, ip)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.useTotp(ctx, user, code)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		s.registerFailure(ctx, user, ip)
+		return eris.New("Code is invalid")
+	}
+
+	return nil
+}
+
+func (s *MfaService) registerFailure(ctx context.Context, user *User, ip string) {
+	err := s.lockoutService.RegisterFailure(ctx, user, user.Username, ip)
+	if err != nil {
+		s.log.With(zap.Error(err)).Error("could not register failed second factor")
+	}
+}
+
+// useTotp validates code of enabled second factor, rejecting replay of already accepted time step
+func (s *MfaService) useTotp(ctx context.Context, user *User, code string) (bool, error) {
+	counter, ok, err := s.validateTotp(user, code)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	isUsed, err := s.repository.UseTotpCounter(ctx, user.ID, counter)
+	if err != nil {
+		return false, eris.Wrap(err, "could not store totp counter")
+	}
+
+	return isUsed, nil
+}
+
+// useRecoveryCode consumes matching recovery code and lets the user know one was used
+func (s *MfaService) useRecoveryCode(ctx context.Context, user *User, code string) (bool, error) {
+	code = normalizeRecoveryCode(code)
+
+	for _, codeHash := range user.MfaRecoveryCodes {
+		if !passport.Match(code, codeHash) {
+			continue
+		}
+
+		isUsed, err := s.repository.UseMfaRecoveryCode(ctx, user.ID, codeHash)
+		if err != nil {
+			return false, eris.Wrap(err, "could not consume recovery code")
+		}
+
+		if !isUsed {
+			return false, nil
+		}
+
+		remaining := len(user.MfaRecoveryCodes) - 1
+
+		s.notificationFacade.Publish(ctx, "email", facade.Message{
+			Topic:     "identity",
+			Header:    "Recovery Code Used",
+			Body:      fmt.Sprintf("A recovery code was used to sign in to your account. You have %d recovery codes left. If this was not you, reset your password immediately.", remaining),
+			Params:    map[string]string{"email": user.Email},
+			Meta:      nil,
+			Timestamp: time.Now().Unix(),
+		}, user.ID.Hex())
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (s *MfaService) generateRecoveryCodes(ctx context.Context, user *User) ([]string, error) {
+	codes := make([]string, 0, s.conf.Mfa.RecoveryCodes)
+	codeHashes := make([]string, 0, s.conf.Mfa.RecoveryCodes)
+
+	for i := 0; i < s.conf.Mfa.RecoveryCodes; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, eris.Wrap(err, "could not generate recovery code")
+		}
+
+		codeHash, err := passport.Hash(normalizeRecoveryCode(code))
+		if err != nil {
+			return nil, eris.Wrap(err, "could not hash recovery code")
+		}
+
+		codes = append(codes, code)
+		codeHashes = append(codeHashes, codeHash)
+	}
+
+	err := s.repository.SetMfaRecoveryCodes(ctx, user.ID, codeHashes)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not store recovery codes")
+	}
+
+	return codes, nil
+}
+
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// generateRecoveryCode generates code formatted as xxxxx-xxxxx without easily confused characters
+func generateRecoveryCode() (string, error) {
+	code := make([]byte, 0, 11)
+	max := big.NewInt(int64(len(recoveryCodeAlphabet)))
+
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			code = append(code, '-')
+		}
+
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+
+		code = append(code, recoveryCodeAlphabet[n.Int64()])
+	}
+
+	return string(code), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func (s *MfaService) validateTotp(user *User, code string) (int64, bool, error) {
+	secret, err := passport.Decrypt(s.conf.Mfa.EncryptionKey, user.TotpSecret, nil)
+	if err != nil {
+		return 0, false, eris.Wrap(err, "could not decrypt totp secret")
+	}
+
+	counter, ok := passport.ValidateTOTP(secret, code, time.Now().UTC(), s.conf.Mfa.TotpSkew)
+
+	return counter, ok, nil
+}
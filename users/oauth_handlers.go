@@ -0,0 +1,317 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/georgi-georgiev/blunder"
+	"github.com/georgi-georgiev/passport"
+	"github.com/georgi-georgiev/passport/clients"
+	"github.com/georgi-georgiev/passport/payloads"
+	"github.com/georgi-georgiev/passport/responses"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuthorizeHandler godoc
+// @Summary Authorize
+// @Description OAuth 2.0 authorization endpoint issuing authorization codes, the user authenticates with basic auth or bearer token
+// @Tags identity
+// @Produce  json
+// @Security BasicAuth
+// @Param response_type query string true "code"
+// @Param client_id query string true "client id"
+// @Param redirect_uri query string true "redirect uri"
+// @Param scope query string false "openid email profile"
+// @Param state query string false "state"
+// @Param nonce query string false "nonce"
+// @Param code_challenge query string false "PKCE code challenge"
+// @Param code_challenge_method query string false "S256"
+// @Success 302
+// @Router /authorize [get]
+func (h *UserHandlers) Authorize(c *gin.Context) {
+	request := AuthorizationRequest{
+		ResponseType:        c.Request.FormValue("response_type"),
+		ClientID:            c.Request.FormValue("client_id"),
+		RedirectURI:         c.Request.FormValue("redirect_uri"),
+		Scope:               c.Request.FormValue("scope"),
+		State:               c.Request.FormValue("state"),
+		Nonce:               c.Request.FormValue("nonce"),
+		CodeChallenge:       c.Request.FormValue("code_challenge"),
+		CodeChallengeMethod: c.Request.FormValue("code_challenge_method"),
+	}
+
+	client, err := h.oauthService.GetAuthorizationClient(c.Request.Context(), request)
+	if err != nil {
+		h.oauthError(c, err)
+		return
+	}
+
+	user, err := h.authorizingUser(c, &request)
+	if h.lockedOut(c, err) {
+		return
+	}
+
+	if err != nil || user == nil {
+		c.Header("WWW-Authenticate", `Basic realm="passport"`)
+		c.JSON(http.StatusUnauthorized, blunder.Unauthorized())
+		return
+	}
+
+	code, err := h.oauthService.Authorize(c.Request.Context(), client, user, request)
+
+	redirectURI, parseErr := url.Parse(request.RedirectURI)
+	if parseErr != nil {
+		c.JSON(http.StatusBadRequest, blunder.BadRequest())
+		return
+	}
+
+	query := redirectURI.Query()
+
+	var oauthErr *passport.OAuthError
+	if errors.As(err, &oauthErr) {
+		query.Set("error", oauthErr.Code)
+		query.Set("error_description", oauthErr.Description)
+	} else if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	} else {
+		query.Set("code", code)
+	}
+
+	if request.State != "" {
+		query.Set("state", request.State)
+	}
+
+	redirectURI.RawQuery = query.Encode()
+
+	c.Redirect(http.StatusFound, redirectURI.String())
+}
+
+// authorizingUser authenticates the resource owner at the authorization endpoint
+func (h *UserHandlers) authorizingUser(c *gin.Context, request *AuthorizationRequest) (*User, error) {
+	username, password, ok := c.Request.BasicAuth()
+	if ok {
+		user, err := h.userService.AuthenticateUser(c.Request.Context(), username, password, c.ClientIP())
+		if err != nil {
+			return nil, err
+		}
+
+		if !user.IsActive || !user.IsVerified {
+			return nil, nil
+		}
+
+		requiresChallenge, err := h.userService.assessLogin(c.Request.Context(), user)
+		if err != nil {
+			return nil, err
+		}
+
+		// password alone is not enough once second factor is enabled or the login is risky, bearer token
+		// obtained by completing the challenge at the token endpoint is required
+		if user.IsTotpEnabled || requiresChallenge {
+			return nil, nil
+		}
+
+		request.AuthTime = time.Now().UTC()
+		request.AuthMethods = []string{passport.AmrPassword}
+
+		return user, nil
+	}
+
+	headerItems := strings.Split(c.Request.Header.Get("Authorization"), " ")
+	if len(headerItems) < 2 {
+		return nil, nil
+	}
+
+	claims, err := h.userService.ValidateToken(c.Request.Context(), headerItems[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.IsSessionToken() {
+		return nil, nil
+	}
+
+	userId, err := primitive.ObjectIDFromHex(claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := h.userService.GetById(c.Request.Context(), userId)
+	if err != nil || user == nil || !user.IsActive || !user.IsVerified || !user.HasTokenVersion(claims.TokenVersion) {
+		return nil, err
+	}
+
+	// code inherits authentication of the session rather than the moment of consent
+	request.AuthTime = time.Unix(claims.AuthTime, 0).UTC()
+	request.AuthMethods = claims.Amr
+
+	return user, nil
+}
+
+// DeviceAuthorizationHandler godoc
+// @Summary Device authorization
+// @Description Starts RFC 8628 device authorization for clients without a browser
+// @Tags identity
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param client_id formData string true "client id"
+// @Param scope formData string false "openid email profile"
+// @Success 200 {object} responses.DeviceAuthorizationResponse
+// @Router /device/code [post]
+func (h *UserHandlers) DeviceAuthorization(c *gin.Context) {
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		h.oauthError(c, err)
+		return
+	}
+
+	response, err := h.oauthService.RequestDeviceCode(c.Request.Context(), client, c.PostForm("scope"))
+	if err != nil {
+		h.oauthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, response)
+}
+
+// GetDeviceCodeHandler godoc
+// @Summary Get device code
+// @Description Returns the client and scope behind a user code so the user can confirm the request
+// @Tags identity
+// @Produce  json
+// @Security OAuth2Application
+// @Param user_code query string true "user code"
+// @Success 200 {object} responses.DeviceCodeResponse
+// @Router /device [get]
+func (h *UserHandlers) GetDeviceCode(c *gin.Context) {
+	deviceCode, client, err := h.oauthService.GetPendingDeviceCode(c.Request.Context(), c.Query("user_code"))
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	if deviceCode == nil {
+		c.JSON(http.StatusNotFound, blunder.NotFound())
+		return
+	}
+
+	c.JSON(http.StatusOK, responses.DeviceCodeResponse{ClientID: client.ClientID, ClientName: client.Name, Scope: deviceCode.Scope})
+}
+
+// VerifyDeviceCodeHandler godoc
+// @Summary Verify device code
+// @Description Approves or denies device authorization identified by the user code
+// @Tags identity
+// @Accept  json
+// @Produce  json
+// @Security OAuth2Application
+// @Param payload body payloads.VerifyDeviceCodePayload true "payload"
+// @Success 204
+// @Router /device [post]
+func (h *UserHandlers) VerifyDeviceCode(c *gin.Context) {
+	var payload payloads.VerifyDeviceCodePayload
+	errors := h.blunder.BindJson(c.Request, &payload)
+	if errors != nil {
+		for _, err := range errors {
+			h.blunder.GinAdd(c, err)
+		}
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	err := h.oauthService.DecideDeviceCode(c.Request.Context(), user, payload.UserCode, payload.Approve, h.session(c))
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// authenticateClient authenticates client with basic auth or client_id and client_secret form fields
+func (h *UserHandlers) authenticateClient(c *gin.Context) (*clients.Client, error) {
+	clientID, clientSecret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID = c.PostForm("client_id")
+		clientSecret = c.PostForm("client_secret")
+	}
+
+	return h.clientService.AuthenticateClient(c.Request.Context(), clientID, clientSecret)
+}
+
+// oauthToken serves the token endpoint for requests carrying grant_type as defined in RFC 6749
+func (h *UserHandlers) oauthToken(c *gin.Context, grantType string) {
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		h.oauthError(c, err)
+		return
+	}
+
+	var tokens *Tokens
+
+	switch grantType {
+	case clients.GrantAuthorizationCode:
+		tokens, err = h.oauthService.ExchangeAuthorizationCode(c.Request.Context(), client, c.PostForm("code"), c.PostForm("redirect_uri"), c.PostForm("code_verifier"))
+	case clients.GrantRefreshToken:
+		tokens, err = h.oauthService.RefreshToken(c.Request.Context(), client, c.PostForm("refresh_token"))
+	case clients.GrantClientCredentials:
+		tokens = &Tokens{}
+		tokens.AccessToken, tokens.ExpiresIn, tokens.Scope, err = h.clientService.ClientCredentialsToken(c.Request.Context(), client, c.PostForm("scope"))
+	case clients.GrantTokenExchange:
+		tokens, err = h.oauthService.ExchangeToken(c.Request.Context(), client, TokenExchangeRequest{
+			SubjectToken:       c.PostForm("subject_token"),
+			SubjectTokenType:   c.PostForm("subject_token_type"),
+			ActorToken:         c.PostForm("actor_token"),
+			ActorTokenType:     c.PostForm("actor_token_type"),
+			RequestedTokenType: c.PostForm("requested_token_type"),
+			Audience:           c.PostForm("audience"),
+			Scope:              c.PostForm("scope"),
+			Rights:             strings.Fields(c.PostForm("rights")),
+		})
+	case clients.GrantDeviceCode:
+		tokens, err = h.oauthService.ExchangeDeviceCode(c.Request.Context(), client, c.PostForm("device_code"))
+	default:
+		err = passport.NewOAuthError(http.StatusBadRequest, "unsupported_grant_type", "Grant type is not supported")
+	}
+
+	if err != nil {
+		h.oauthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, responses.OAuthTokenResponse{
+		AccessToken:     tokens.AccessToken,
+		TokenType:       "Bearer",
+		ExpiresIn:       tokens.ExpiresIn - time.Now().Unix(),
+		RefreshToken:    tokens.RefreshToken,
+		IDToken:         tokens.IDToken,
+		Scope:           tokens.Scope,
+		IssuedTokenType: tokens.IssuedTokenType,
+	})
+}
+
+// oauthError writes RFC 6749 error response, other errors are handled by blunder
+func (h *UserHandlers) oauthError(c *gin.Context, err error) {
+	var oauthErr *passport.OAuthError
+	if !errors.As(err, &oauthErr) {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	if oauthErr.Status == http.StatusUnauthorized {
+		c.Header("WWW-Authenticate", `Basic realm="passport"`)
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(oauthErr.Status, responses.OAuthErrorResponse{Error: oauthErr.Code, ErrorDescription: oauthErr.Description})
+}
@@ -0,0 +1,701 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/georgi-georgiev/passport"
+	"github.com/georgi-georgiev/passport/audit"
+	"github.com/georgi-georgiev/passport/clients"
+	"github.com/georgi-georgiev/passport/permissions"
+	"github.com/georgi-georgiev/passport/responses"
+	"github.com/rotisserie/eris"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+type OAuthService struct {
+	userService                 *UserService
+	clientService               *clients.ClientService
+	authorizationCodeRepository *AuthorizationCodeRepository
+	deviceCodeRepository        *DeviceCodeRepository
+	conf                        *passport.Config
+	log                         *zap.Logger
+}
+
+func NewOAuthService(userService *UserService, clientService *clients.ClientService, authorizationCodeRepository *AuthorizationCodeRepository, deviceCodeRepository *DeviceCodeRepository, conf *passport.Config, log *zap.Logger) *OAuthService {
+	return &OAuthService{userService: userService, clientService: clientService, authorizationCodeRepository: authorizationCodeRepository, deviceCodeRepository: deviceCodeRepository, conf: conf, log: log}
+}
+
+// GetAuthorizationClient resolves the client of authorization request, its errors must not be redirected to the client
+func (s *OAuthService) GetAuthorizationClient(ctx context.Context, request AuthorizationRequest) (*clients.Client, error) {
+	client, err := s.clientService.GetByClientID(ctx, request.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		return nil, passport.InvalidRequest("Client is unknown")
+	}
+
+	if !client.AllowsRedirectURI(request.RedirectURI) {
+		return nil, passport.InvalidRequest("Redirect uri is not registered for the client")
+	}
+
+	return client, nil
+}
+
+// Authorize issues authorization code for the authenticated user, PKCE is mandatory for public clients
+func (s *OAuthService) Authorize(ctx context.Context, client *clients.Client, user *User, request AuthorizationRequest) (string, error) {
+	if request.ResponseType != "code" {
+		return "", passport.NewOAuthError(http.StatusBadRequest, "unsupported_response_type", "Only code response type is supported")
+	}
+
+	if !client.AllowsGrant(clients.GrantAuthorizationCode) {
+		return "", passport.UnauthorizedClient("Client is not allowed to use authorization code grant")
+	}
+
+	if !client.AllowsScope(request.Scope) {
+		return "", passport.InvalidScope("Requested scope is not allowed for the client")
+	}
+
+	if request.CodeChallenge != "" && request.CodeChallengeMethod == "" {
+		request.CodeChallengeMethod = "plain"
+	}
+
+	if client.IsPublic && (request.CodeChallenge == "" || request.CodeChallengeMethod != "S256") {
+		return "", passport.InvalidRequest("Public clients must use S256 code challenge")
+	}
+
+	if request.CodeChallenge != "" && request.CodeChallengeMethod != "S256" && request.CodeChallengeMethod != "plain" {
+		return "", passport.InvalidRequest("Code challenge method is not supported")
+	}
+
+	code, err := generateCode(32)
+	if err != nil {
+		return "", eris.Wrap(err, "could not generate authorization code")
+	}
+
+	authorizationCode := NewAuthorizationCode(passport.HashToken(code), user.ID, request, s.conf.Token.AuthorizationCodeTTL)
+
+	_, err = s.authorizationCodeRepository.Create(ctx, authorizationCode)
+	if err != nil {
+		return "", eris.Wrap(err, "could not store authorization code")
+	}
+
+	return code, nil
+}
+
+// ExchangeAuthorizationCode redeems single use authorization code for tokens
+func (s *OAuthService) ExchangeAuthorizationCode(ctx context.Context, client *clients.Client, code string, redirectURI string, codeVerifier string) (*Tokens, error) {
+	if !client.AllowsGrant(clients.GrantAuthorizationCode) {
+		return nil, passport.UnauthorizedClient("Client is not allowed to use authorization code grant")
+	}
+
+	authorizationCode, err := s.authorizationCodeRepository.GetByHash(ctx, passport.HashToken(code))
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get authorization code")
+	}
+
+	if authorizationCode == nil || authorizationCode.ClientID != client.ClientID || authorizationCode.RedirectURI != redirectURI {
+		return nil, passport.InvalidGrant("Authorization code is invalid")
+	}
+
+	if time.Now().UTC().After(authorizationCode.ExpiresOn) {
+		return nil, passport.InvalidGrant("Authorization code is expired")
+	}
+
+	if !verifyCodeChallenge(authorizationCode.CodeChallenge, authorizationCode.CodeChallengeMethod, codeVerifier) {
+		return nil, passport.InvalidGrant("Code verifier does not match")
+	}
+
+	isMarked, err := s.authorizationCodeRepository.MarkUsed(ctx, authorizationCode.ID)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not mark authorization code as used")
+	}
+
+	if !isMarked {
+		s.log.Warn("authorization code reuse detected", zap.String("clientId", client.ClientID), zap.String("userId", authorizationCode.UserID.Hex()))
+		return nil, passport.InvalidGrant("Authorization code is already used")
+	}
+
+	user, err := s.userService.GetById(ctx, authorizationCode.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || !user.IsActive {
+		return nil, passport.InvalidGrant("Authorization code is invalid")
+	}
+
+	return s.userService.IssueTokens(ctx, user, TokenRequest{
+		ClientID:       client.ClientID,
+		Scope:          authorizationCode.Scope,
+		Nonce:          authorizationCode.Nonce,
+		AuthTime:       authorizationCode.AuthTime,
+		AuthMethods:    authorizationCode.AuthMethods,
+		NoRefreshToken: !client.AllowsGrant(clients.GrantRefreshToken),
+	})
+}
+
+// RefreshToken rotates refresh token issued to the client
+func (s *OAuthService) RefreshToken(ctx context.Context, client *clients.Client, refreshToken string) (*Tokens, error) {
+	if !client.AllowsGrant(clients.GrantRefreshToken) {
+		return nil, passport.UnauthorizedClient("Client is not allowed to use refresh token grant")
+	}
+
+	tokens, err := s.userService.RefreshToken(ctx, refreshToken, client.ClientID)
+	if err != nil {
+		s.log.With(zap.Error(err)).Info("could not refresh token")
+		return nil, passport.InvalidGrant("Refresh token is invalid")
+	}
+
+	return tokens, nil
+}
+
+// RequestDeviceCode starts device authorization as described in RFC 8628
+func (s *OAuthService) RequestDeviceCode(ctx context.Context, client *clients.Client, scope string) (*responses.DeviceAuthorizationResponse, error) {
+	if !client.AllowsGrant(clients.GrantDeviceCode) {
+		return nil, passport.UnauthorizedClient("Client is not allowed to use device code grant")
+	}
+
+	if !client.AllowsScope(scope) {
+		return nil, passport.InvalidScope("Requested scope is not allowed for the client")
+	}
+
+	deviceCode, err := generateCode(32)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not generate device code")
+	}
+
+	userCode, err := generateUserCode()
+	if err != nil {
+		return nil, eris.Wrap(err, "could not generate user code")
+	}
+
+	record := NewDeviceCode(passport.HashToken(deviceCode), passport.HashToken(normalizeUserCode(userCode)), client.ClientID, scope, s.conf.Token.DeviceCodeInterval, s.conf.Token.DeviceCodeTTL)
+
+	_, err = s.deviceCodeRepository.Create(ctx, record)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not store device code")
+	}
+
+	verificationURI := s.conf.Token.Issuer + "/device"
+
+	return &responses.DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(userCode),
+		ExpiresIn:               int64(s.conf.Token.DeviceCodeTTL.Seconds()),
+		Interval:                record.Interval,
+	}, nil
+}
+
+// GetPendingDeviceCode returns device code waiting for the user decision together with the requesting client
+func (s *OAuthService) GetPendingDeviceCode(ctx context.Context, userCode string) (*DeviceCode, *clients.Client, error) {
+	deviceCode, err := s.deviceCodeRepository.GetPendingByUserCodeHash(ctx, passport.HashToken(normalizeUserCode(userCode)))
+	if err != nil {
+		return nil, nil, eris.Wrap(err, "could not get device code")
+	}
+
+	if deviceCode == nil {
+		return nil, nil, nil
+	}
+
+	client, err := s.clientService.GetByClientID(ctx, deviceCode.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if client == nil {
+		return nil, nil, nil
+	}
+
+	return deviceCode, client, nil
+}
+
+// DecideDeviceCode approves or denies device authorization on behalf of the authenticated user
+func (s *OAuthService) DecideDeviceCode(ctx context.Context, user *User, userCode string, approve bool, session TokenRequest) error {
+	deviceCode, _, err := s.GetPendingDeviceCode(ctx, userCode)
+	if err != nil {
+		return err
+	}
+
+	if deviceCode == nil {
+		return eris.New("User code is invalid or expired")
+	}
+
+	status := DeviceCodeDenied
+	if approve {
+		status = DeviceCodeApproved
+	}
+
+	isDecided, err := s.deviceCodeRepository.Decide(ctx, deviceCode.ID, status, user.ID, session.AuthTime, session.AuthMethods)
+	if err != nil {
+		return eris.Wrap(err, "could not update device code")
+	}
+
+	if !isDecided {
+		return eris.New("User code is invalid or expired")
+	}
+
+	return nil
+}
+
+// ExchangeDeviceCode answers device polling, issuing tokens once the user approved the request
+func (s *OAuthService) ExchangeDeviceCode(ctx context.Context, client *clients.Client, code string) (*Tokens, error) {
+	if !client.AllowsGrant(clients.GrantDeviceCode) {
+		return nil, passport.UnauthorizedClient("Client is not allowed to use device code grant")
+	}
+
+	deviceCode, err := s.deviceCodeRepository.GetByDeviceCodeHash(ctx, passport.HashToken(code))
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get device code")
+	}
+
+	if deviceCode == nil || deviceCode.ClientID != client.ClientID {
+		return nil, passport.InvalidGrant("Device code is invalid")
+	}
+
+	now := time.Now().UTC()
+
+	if now.After(deviceCode.ExpiresOn) {
+		return nil, passport.NewOAuthError(http.StatusBadRequest, "expired_token", "Device code is expired")
+	}
+
+	switch deviceCode.Status {
+	case DeviceCodePending:
+		interval := deviceCode.Interval
+		isTooFast := deviceCode.LastPolledOn != nil && now.Sub(*deviceCode.LastPolledOn) < time.Duration(interval)*time.Second
+		if isTooFast {
+			interval += 5
+		}
+
+		err = s.deviceCodeRepository.SetPolled(ctx, deviceCode.ID, interval)
+		if err != nil {
+			return nil, eris.Wrap(err, "could not update device code")
+		}
+
+		if isTooFast {
+			return nil, passport.NewOAuthError(http.StatusBadRequest, "slow_down", "Polling too frequently")
+		}
+
+		return nil, passport.NewOAuthError(http.StatusBadRequest, "authorization_pending", "User has not yet completed the authorization")
+	case DeviceCodeDenied:
+		return nil, passport.NewOAuthError(http.StatusBadRequest, "access_denied", "User denied the authorization")
+	case DeviceCodeUsed:
+		return nil, passport.InvalidGrant("Device code is already used")
+	}
+
+	isMarked, err := s.deviceCodeRepository.MarkUsed(ctx, deviceCode.ID)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not mark device code as used")
+	}
+
+	if !isMarked {
+		return nil, passport.InvalidGrant("Device code is already used")
+	}
+
+	user, err := s.userService.GetById(ctx, deviceCode.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || !user.IsActive {
+		return nil, passport.InvalidGrant("Device code is invalid")
+	}
+
+	return s.userService.IssueTokens(ctx, user, TokenRequest{
+		ClientID:       client.ClientID,
+		Scope:          deviceCode.Scope,
+		AuthTime:       *deviceCode.AuthTime,
+		AuthMethods:    deviceCode.AuthMethods,
+		NoRefreshToken: !client.AllowsGrant(clients.GrantRefreshToken),
+	})
+}
+
+// ExchangeToken issues downscoped access token for the subject token as described in RFC 8693,
+// or impersonation token when the subject is given by user id and the actor holds the impersonation right
+func (s *OAuthService) ExchangeToken(ctx context.Context, client *clients.Client, request TokenExchangeRequest) (*Tokens, error) {
+	if !client.AllowsGrant(clients.GrantTokenExchange) {
+		return nil, passport.UnauthorizedClient("Client is not allowed to use token exchange grant")
+	}
+
+	if request.RequestedTokenType != "" && request.RequestedTokenType != TokenTypeAccessToken {
+		return nil, passport.InvalidRequest("Requested token type is not supported")
+	}
+
+	// exchanged token is bound to the service it is meant for and is never accepted by this one
+	if request.Audience == "" {
+		return nil, passport.InvalidRequest("Audience is required")
+	}
+
+	if request.Audience == s.conf.Token.Issuer || !client.AllowsAudience(request.Audience) {
+		return nil, passport.NewOAuthError(http.StatusBadRequest, "invalid_target", "Requested audience is not allowed for the client")
+	}
+
+	var actor *passport.UserClaims
+	if request.ActorToken != "" {
+		if request.ActorTokenType != TokenTypeAccessToken {
+			return nil, passport.InvalidRequest("Actor token type is not supported")
+		}
+
+		var err error
+		actor, err = s.userService.ValidateToken(ctx, request.ActorToken)
+		if err != nil {
+			s.log.With(zap.Error(err)).Info("could not validate actor token")
+			return nil, passport.InvalidGrant("Actor token is invalid")
+		}
+	}
+
+	var subject *passport.UserClaims
+	var userId primitive.ObjectID
+	var err error
+
+	switch request.SubjectTokenType {
+	case TokenTypeAccessToken:
+		subject, err = s.userService.ValidateToken(ctx, request.SubjectToken)
+		if err != nil {
+			s.log.With(zap.Error(err)).Info("could not validate subject token")
+			return nil, passport.InvalidGrant("Subject token is invalid")
+		}
+
+		if subject.IsClient {
+			return nil, passport.InvalidGrant("Subject token must belong to a user")
+		}
+
+		userId, err = primitive.ObjectIDFromHex(subject.Subject)
+	case TokenTypeUserID:
+		if actor == nil {
+			return nil, passport.InvalidRequest("Actor token is required for impersonation")
+		}
+
+		userId, err = primitive.ObjectIDFromHex(request.SubjectToken)
+		if err != nil {
+			return nil, passport.InvalidGrant("Subject is invalid")
+		}
+
+		err = s.authorizeImpersonation(ctx, actor)
+		if err != nil {
+			s.log.Warn("impersonation denied", zap.String("actor", actor.Subject), zap.String("clientId", client.ClientID), zap.Error(err))
+			s.recordImpersonation(ctx, actor, userId.Hex(), client, audit.OutcomeFailure, err.Error(), nil)
+			return nil, err
+		}
+	default:
+		return nil, passport.InvalidRequest("Subject token type is not supported")
+	}
+
+	if err != nil {
+		return nil, passport.InvalidGrant("Subject is invalid")
+	}
+
+	user, err := s.userService.GetById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || !user.IsActive || !user.IsVerified {
+		return nil, passport.InvalidGrant("Subject is not active")
+	}
+
+	if subject != nil && !user.HasTokenVersion(subject.TokenVersion) {
+		return nil, passport.InvalidGrant("Subject token is invalid")
+	}
+
+	scope := request.Scope
+	if subject != nil && subject.Scope != "" {
+		if scope == "" {
+			scope = subject.Scope
+		}
+
+		if !isScopeSubset(scope, subject.Scope) {
+			return nil, passport.InvalidScope("Requested scope exceeds subject token scope")
+		}
+	}
+
+	if !client.AllowsScope(scope) {
+		return nil, passport.InvalidScope("Requested scope is not allowed for the client")
+	}
+
+	claims := s.userService.MapToUserClaims(user, TokenRequest{ClientID: client.ClientID, Scope: scope})
+	if claims == nil {
+		return nil, eris.New("could not map user claims")
+	}
+
+	if subject == nil {
+		err = s.checkImpersonable(ctx, claims)
+		if err != nil {
+			s.log.Warn("impersonation denied", zap.String("actor", actor.Subject), zap.String("clientId", client.ClientID), zap.Error(err))
+			s.recordImpersonation(ctx, actor, user.ID.Hex(), client, audit.OutcomeFailure, err.Error(), nil)
+			return nil, err
+		}
+	}
+
+	// exchanged token never carries more rights than the user currently has or the subject token had
+	allowedRights := claims.Rights
+	if subject != nil {
+		allowedRights = intersect(allowedRights, subject.Rights)
+	}
+
+	rights, err := s.narrowRights(ctx, allowedRights, request.Rights)
+	if err != nil {
+		return nil, err
+	}
+
+	downscope(claims, rights, request.Audience)
+
+	claims.Act = &passport.Actor{Subject: client.ClientID, ClientID: client.ClientID}
+	if actor != nil {
+		claims.Act = &passport.Actor{Subject: actor.Subject, ClientID: actor.ClientID}
+	}
+
+	// authentication is the one of whoever is present, the subject when delegating or the actor when impersonating
+	authenticated := actor
+	if subject != nil {
+		authenticated = subject
+	}
+
+	// delegated token ends together with the session of the subject token, impersonation together with the session of the actor
+	claims.SessionID = authenticated.SessionID
+	claims.AuthTime = authenticated.AuthTime
+	claims.Amr = authenticated.Amr
+	claims.Acr = authenticated.Acr
+
+	if subject != nil {
+		claims.Act.Act = subject.Act
+
+		if subject.ExpiresAt < claims.ExpiresAt {
+			claims.ExpiresAt = subject.ExpiresAt
+		}
+	}
+
+	accessToken, err := s.userService.keyManager.SignAccessToken(claims)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not sign exchanged token")
+	}
+
+	if subject == nil {
+		s.recordImpersonation(ctx, actor, user.ID.Hex(), client, audit.OutcomeSuccess, "", map[string]string{"jti": claims.Id, "audience": claims.Audience})
+	}
+
+	return &Tokens{AccessToken: accessToken, Scope: scope, ExpiresIn: claims.ExpiresAt, IssuedTokenType: TokenTypeAccessToken}, nil
+}
+
+// downscope limits exchanged token to the rights and the audience it is exchanged for. Role is dropped,
+// it would grant whatever the role allows regardless of the narrowed rights.
+func downscope(claims *passport.UserClaims, rights []string, audience string) {
+	claims.Role = ""
+	claims.RoleId = ""
+	claims.IsAdmin = false
+	claims.Rights = rights
+	claims.Audience = audience
+}
+
+// authorizeImpersonation checks the actor against its current account rather than the claims of the actor token,
+// so rights taken away or sessions revoked since the token was issued stop impersonation right away
+func (s *OAuthService) authorizeImpersonation(ctx context.Context, actor *passport.UserClaims) error {
+	if actor.IsClient || actor.Act != nil {
+		return passport.InvalidGrant("Actor token must belong to a user")
+	}
+
+	actorId, err := primitive.ObjectIDFromHex(actor.Subject)
+	if err != nil {
+		return passport.InvalidGrant("Actor token is invalid")
+	}
+
+	actorUser, err := s.userService.GetById(ctx, actorId)
+	if err != nil {
+		return err
+	}
+
+	if actorUser == nil || !actorUser.IsActive || !actorUser.HasTokenVersion(actor.TokenVersion) {
+		return passport.InvalidGrant("Actor token is invalid")
+	}
+
+	actorRights := make([]string, 0, len(actorUser.Rights))
+	for _, right := range actorUser.Rights {
+		actorRights = append(actorRights, right.Hex())
+	}
+
+	canImpersonate, err := s.hasRight(ctx, actorRights, s.conf.Token.ImpersonationRight)
+	if err != nil {
+		return err
+	}
+
+	if !canImpersonate {
+		return passport.InvalidGrant("Actor is not allowed to impersonate users")
+	}
+
+	return nil
+}
+
+// checkImpersonable refuses to impersonate admins and other impersonators, who could otherwise be used to escalate privileges
+func (s *OAuthService) checkImpersonable(ctx context.Context, claims *passport.UserClaims) error {
+	if claims.Role == "admin" {
+		return passport.InvalidGrant("User cannot be impersonated")
+	}
+
+	canImpersonate, err := s.hasRight(ctx, claims.Rights, s.conf.Token.ImpersonationRight)
+	if err != nil {
+		return err
+	}
+
+	if canImpersonate {
+		return passport.InvalidGrant("User cannot be impersonated")
+	}
+
+	return nil
+}
+
+func (s *OAuthService) recordImpersonation(ctx context.Context, actor *passport.UserClaims, subjectID string, client *clients.Client, outcome string, reason string, details map[string]string) {
+	s.userService.auditService.Record(ctx, &audit.Event{
+		Action:    audit.ActionImpersonate,
+		Outcome:   outcome,
+		ActorID:   actor.Subject,
+		SubjectID: subjectID,
+		ClientID:  client.ClientID,
+		Reason:    reason,
+		Details:   details,
+	})
+}
+
+// hasRight reports whether any of the right ids carries the right name
+func (s *OAuthService) hasRight(ctx context.Context, rightIds []string, name string) (bool, error) {
+	rights, err := s.loadRights(ctx, rightIds)
+	if err != nil {
+		return false, err
+	}
+
+	for _, right := range rights {
+		if right.Name == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// narrowRights returns ids of the requested right names, all of them must be among allowed right ids
+func (s *OAuthService) narrowRights(ctx context.Context, allowedRightIds []string, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return allowedRightIds, nil
+	}
+
+	rights, err := s.loadRights(ctx, allowedRightIds)
+	if err != nil {
+		return nil, err
+	}
+
+	rightIds := make([]string, 0)
+	for _, name := range requested {
+		index := slices.IndexFunc(rights, func(right permissions.Right) bool { return right.Name == name })
+		if index < 0 {
+			return nil, passport.InvalidScope("Requested rights exceed subject rights")
+		}
+
+		rightIds = append(rightIds, rights[index].ID.Hex())
+	}
+
+	return rightIds, nil
+}
+
+func (s *OAuthService) loadRights(ctx context.Context, rightIds []string) ([]permissions.Right, error) {
+	ids := make([]primitive.ObjectID, 0)
+	for _, rightId := range rightIds {
+		id, err := primitive.ObjectIDFromHex(rightId)
+		if err != nil {
+			return nil, eris.Wrap(err, "could not convert right id hex to primitive")
+		}
+
+		ids = append(ids, id)
+	}
+
+	if len(ids) == 0 {
+		return []permissions.Right{}, nil
+	}
+
+	rights, err := s.userService.rightService.GetManyByIds(ctx, ids)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get rights")
+	}
+
+	return rights, nil
+}
+
+func isScopeSubset(scope string, of string) bool {
+	allowed := strings.Fields(of)
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(allowed, s) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func intersect(a []string, b []string) []string {
+	result := make([]string, 0)
+	for _, item := range a {
+		if slices.Contains(b, item) {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// generateUserCode generates code easy to type on another device, formatted as XXXX-XXXX
+func generateUserCode() (string, error) {
+	code := make([]byte, 0, 9)
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+
+	for i := 0; i < 8; i++ {
+		if i == 4 {
+			code = append(code, '-')
+		}
+
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+
+		code = append(code, userCodeAlphabet[n.Int64()])
+	}
+
+	return string(code), nil
+}
+
+func normalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, userCode)
+}
+
+func verifyCodeChallenge(challenge string, method string, verifier string) bool {
+	if challenge == "" {
+		return verifier == ""
+	}
+
+	if method == "S256" {
+		sum := sha256.Sum256([]byte(verifier))
+		verifier = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(verifier)) == 1
+}
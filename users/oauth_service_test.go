@@ -0,0 +1,87 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/georgi-georgiev/passport"
+)
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	// verifier and challenge of RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	tests := []struct {
+		name      string
+		challenge string
+		method    string
+		verifier  string
+		valid     bool
+	}{
+		{name: "s256 matching verifier", challenge: challenge, method: "S256", verifier: verifier, valid: true},
+		{name: "s256 wrong verifier", challenge: challenge, method: "S256", verifier: verifier + "x"},
+		{name: "s256 challenge sent as verifier", challenge: challenge, method: "S256", verifier: challenge},
+		{name: "s256 missing verifier", challenge: challenge, method: "S256", verifier: ""},
+		{name: "plain matching verifier", challenge: verifier, method: "plain", verifier: verifier, valid: true},
+		{name: "plain wrong verifier", challenge: verifier, method: "plain", verifier: challenge},
+		{name: "no challenge and no verifier", valid: true},
+		{name: "verifier without challenge", verifier: verifier},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid := verifyCodeChallenge(tt.challenge, tt.method, tt.verifier)
+			if valid != tt.valid {
+				t.Fatalf("expected valid %v, got %v", tt.valid, valid)
+			}
+		})
+	}
+}
+
+func TestDownscope(t *testing.T) {
+	tests := []struct {
+		name   string
+		rights []string
+	}{
+		{name: "narrowed rights", rights: []string{"65a1b2c3d4e5f60718293a4c"}},
+		{name: "no rights", rights: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &passport.UserClaims{
+				Role:    "admin",
+				RoleId:  "65a1b2c3d4e5f60718293a4b",
+				IsAdmin: true,
+				Rights:  []string{"65a1b2c3d4e5f60718293a4c", "65a1b2c3d4e5f60718293a4d"},
+				// the subject token of delegation carries session of the user
+				SessionID: "65a1b2c3d4e5f60718293a4e",
+			}
+
+			downscope(claims, tt.rights, "https://api.example.com")
+
+			if claims.Role != "" || claims.RoleId != "" || claims.IsAdmin {
+				t.Fatalf("expected role to be dropped, got %q %q %v", claims.Role, claims.RoleId, claims.IsAdmin)
+			}
+
+			if len(claims.Rights) != len(tt.rights) {
+				t.Fatalf("expected rights %v, got %v", tt.rights, claims.Rights)
+			}
+
+			for i := range tt.rights {
+				if claims.Rights[i] != tt.rights[i] {
+					t.Fatalf("expected rights %v, got %v", tt.rights, claims.Rights)
+				}
+			}
+
+			if claims.Audience != "https://api.example.com" {
+				t.Fatalf("expected audience to be set, got %q", claims.Audience)
+			}
+
+			// exchanged token never stands for the user's own session at this server
+			if claims.IsSessionToken() {
+				t.Fatal("expected exchanged token not to be a session token")
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/georgi-georgiev/blunder"
+	"github.com/georgi-georgiev/passport/clients"
+	"github.com/georgi-georgiev/passport/responses"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OpenIDConfigurationHandler godoc
+// @Summary OpenID configuration
+// @Description OpenID Connect discovery document
+// @Tags identity
+// @Produce  json
+// @Success 200 {object} responses.OpenIDConfiguration
+// @Router /.well-known/openid-configuration [get]
+func (h *UserHandlers) OpenIDConfiguration(c *gin.Context) {
+	issuer := h.userService.conf.Token.Issuer
+
+	c.JSON(http.StatusOK, responses.OpenIDConfiguration{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/authorize",
+		TokenEndpoint:                     issuer + "/token",
+		UserInfoEndpoint:                  issuer + "/userinfo",
+		JwksURI:                           issuer + "/.well-known/jwks.json",
+		RevocationEndpoint:                issuer + "/revoke",
+		IntrospectionEndpoint:             issuer + "/introspect",
+		DeviceAuthorizationEndpoint:       issuer + "/device/code",
+		ScopesSupported:                   []string{"openid", "email", "profile"},
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"password", "authorization_code", "refresh_token", "client_credentials", clients.GrantDeviceCode, clients.GrantTokenExchange},
+		CodeChallengeMethodsSupported:     []string{"S256", "plain"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{h.keyManager.Algorithm()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "amr", "acr", "nonce", "email", "email_verified", "preferred_username"},
+	})
+}
+
+// UserInfoHandler godoc
+// @Summary User info
+// @Description OpenID Connect standard claims of the authenticated user
+// @Tags identity
+// @Produce  json
+// @Security OAuth2Application
+// @Success 200 {object} responses.UserInfoResponse
+// @Router /userinfo [get]
+func (h *UserHandlers) UserInfo(c *gin.Context) {
+	userId, err := primitive.ObjectIDFromHex(c.GetString("userId"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, blunder.Unauthorized())
+		return
+	}
+
+	user, err := h.userService.GetById(c.Request.Context(), userId)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	if user == nil {
+		c.JSON(http.StatusNotFound, blunder.NotFound())
+		return
+	}
+
+	response := responses.UserInfoResponse{
+		Sub:               user.ID.Hex(),
+		Email:             user.Email,
+		EmailVerified:     user.IsVerified,
+		PreferredUsername: user.Username,
+	}
+
+	if user.UpdatedOn != nil {
+		response.UpdatedAt = user.UpdatedOn.Unix()
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,109 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/georgi-georgiev/blunder"
+	"github.com/georgi-georgiev/passport/payloads"
+	"github.com/georgi-georgiev/passport/responses"
+	"github.com/gin-gonic/gin"
+)
+
+// PasswordlessStartHandler godoc
+// @Summary Start passwordless login
+// @Description Emails one-time code and magic link, response does not reveal whether the email is registered
+// @Tags identity
+// @Accept  json
+// @Param payload body payloads.PasswordlessStartPayload true "payload"
+// @Success 202
+// @Failure      400  {object}  blunder.HTTPErrorResponse
+// @Router /passwordless/start [post]
+func (h *UserHandlers) PasswordlessStart(c *gin.Context) {
+	var payload payloads.PasswordlessStartPayload
+	errors := h.blunder.BindJson(c.Request, &payload)
+	if errors != nil {
+		for _, err := range errors {
+			h.blunder.GinAdd(c, err)
+		}
+		return
+	}
+
+	err := h.passwordlessService.SendLoginCode(c.Request.Context(), payload.Email, payload.Scope)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// PasswordlessVerifyHandler godoc
+// @Summary Verify passwordless login
+// @Description Exchanges emailed code together with email, or magic link token, for tokens
+// @Tags identity
+// @Accept  json
+// @Produce  json
+// @Param payload body payloads.PasswordlessVerifyPayload true "payload"
+// @Success 200 {object} responses.TokenResponse
+// @Failure      400  {object}  blunder.HTTPErrorResponse
+// @Failure      403  {object}  responses.MfaRequiredResponse
+// @Router /passwordless/verify [post]
+func (h *UserHandlers) PasswordlessVerify(c *gin.Context) {
+	var payload payloads.PasswordlessVerifyPayload
+	errors := h.blunder.BindJson(c.Request, &payload)
+	if errors != nil {
+		for _, err := range errors {
+			h.blunder.GinAdd(c, err)
+		}
+		return
+	}
+
+	var tokens *Tokens
+	var err error
+
+	switch {
+	case payload.Token != "":
+		tokens, err = h.passwordlessService.VerifyMagicLink(c.Request.Context(), payload.Token)
+	case payload.Email != "" && payload.Code != "":
+		tokens, err = h.passwordlessService.VerifyLoginCode(c.Request.Context(), payload.Email, payload.Code)
+	default:
+		c.JSON(http.StatusBadRequest, blunder.BadRequest())
+		return
+	}
+
+	h.passwordlessTokens(c, tokens, err)
+}
+
+// PasswordlessMagicLinkHandler godoc
+// @Summary Open magic link
+// @Description Exchanges token of the emailed magic link, which mail clients open with GET, for tokens
+// @Tags identity
+// @Produce  json
+// @Param token query string true "magic link token"
+// @Success 200 {object} responses.TokenResponse
+// @Failure      400  {object}  blunder.HTTPErrorResponse
+// @Failure      403  {object}  responses.MfaRequiredResponse
+// @Router /passwordless/verify [get]
+func (h *UserHandlers) PasswordlessMagicLink(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, blunder.BadRequest())
+		return
+	}
+
+	tokens, err := h.passwordlessService.VerifyMagicLink(c.Request.Context(), token)
+	h.passwordlessTokens(c, tokens, err)
+}
+
+func (h *UserHandlers) passwordlessTokens(c *gin.Context, tokens *Tokens, err error) {
+	if h.mfaRequired(c, err) {
+		return
+	}
+
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, responses.TokenResponse{TokenType: "Bearer", AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken, IDToken: tokens.IDToken, ExpiresIn: tokens.ExpiresIn})
+}
@@ -0,0 +1,174 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"github.com/georgi-georgiev/passport/facade"
+	"github.com/rotisserie/eris"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+)
+
+type PasswordlessService struct {
+	notificationFacade  *facade.NotificationFacade
+	userService         *UserService
+	repository          *UserRepository
+	loginCodeRepository *LoginCodeRepository
+	conf                *passport.Config
+	log                 *zap.Logger
+}
+
+func NewPasswordlessService(notificationFacade *facade.NotificationFacade, userService *UserService, repository *UserRepository, loginCodeRepository *LoginCodeRepository, conf *passport.Config, log *zap.Logger) *PasswordlessService {
+	return &PasswordlessService{notificationFacade: notificationFacade, userService: userService, repository: repository, loginCodeRepository: loginCodeRepository, conf: conf, log: log}
+}
+
+// SendLoginCode emails one-time code and magic link to the user. Unknown emails are silently ignored
+// so the endpoint does not reveal which accounts exist.
+func (s *PasswordlessService) SendLoginCode(ctx context.Context, email string, scope string) error {
+	user, err := s.repository.GetByEmail(ctx, email)
+	if err != nil {
+		return eris.Wrap(err, "could not get user by email")
+	}
+
+	if user == nil || !user.IsActive {
+		s.log.Info("passwordless login requested for unknown or inactive email")
+		return nil
+	}
+
+	code, err := generateLoginCode()
+	if err != nil {
+		return eris.Wrap(err, "could not generate login code")
+	}
+
+	codeHash, err := passport.Hash(code)
+	if err != nil {
+		return eris.Wrap(err, "could not hash login code")
+	}
+
+	link, err := generateCode(32)
+	if err != nil {
+		return eris.Wrap(err, "could not generate magic link")
+	}
+
+	err = s.loginCodeRepository.RevokeActive(ctx, user.ID)
+	if err != nil {
+		return eris.Wrap(err, "could not revoke login codes")
+	}
+
+	_, err = s.loginCodeRepository.Create(ctx, NewLoginCode(user.ID, codeHash, passport.HashToken(link), scope, s.conf.Passwordless.CodeTTL))
+	if err != nil {
+		return eris.Wrap(err, "could not store login code")
+	}
+
+	s.notificationFacade.Publish(ctx, "email", facade.Message{
+		Topic:     "identity",
+		Header:    "Sign In",
+		Body:      fmt.Sprintf("Your sign in code is: %s\nOr sign in with the link: %s?token=%s\nBoth expire in %s.", code, s.conf.Passwordless.MagicLinkURL, url.QueryEscape(link), s.conf.Passwordless.CodeTTL),
+		Params:    map[string]string{"email": user.Email},
+		Meta:      nil,
+		Timestamp: time.Now().Unix(),
+	}, user.ID.Hex())
+
+	return nil
+}
+
+// VerifyLoginCode exchanges emailed one-time code for tokens
+func (s *PasswordlessService) VerifyLoginCode(ctx context.Context, email string, code string) (*Tokens, error) {
+	generalErrorMsg := "Login code is invalid or expired"
+
+	user, err := s.repository.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get user by email")
+	}
+
+	if user == nil {
+		return nil, eris.New(generalErrorMsg)
+	}
+
+	loginCode, err := s.loginCodeRepository.ReserveAttempt(ctx, user.ID, s.conf.Passwordless.MaxAttempts)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not count login code attempt")
+	}
+
+	if loginCode == nil || !passport.Match(code, loginCode.CodeHash) {
+		return nil, eris.New(generalErrorMsg)
+	}
+
+	return s.login(ctx, user, loginCode)
+}
+
+// VerifyMagicLink exchanges token from emailed magic link for tokens
+func (s *PasswordlessService) VerifyMagicLink(ctx context.Context, token string) (*Tokens, error) {
+	loginCode, err := s.loginCodeRepository.GetActiveByLinkHash(ctx, passport.HashToken(token))
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get login code")
+	}
+
+	if loginCode == nil {
+		return nil, eris.New("Magic link is invalid or expired")
+	}
+
+	user, err := s.userService.GetById(ctx, loginCode.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, eris.New("Magic link is invalid or expired")
+	}
+
+	return s.login(ctx, user, loginCode)
+}
+
+func (s *PasswordlessService) login(ctx context.Context, user *User, loginCode *LoginCode) (*Tokens, error) {
+	if !user.IsActive {
+		return nil, eris.New("User is not active")
+	}
+
+	isMarked, err := s.loginCodeRepository.MarkUsed(ctx, loginCode.ID)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not mark login code as used")
+	}
+
+	if !isMarked {
+		return nil, eris.New("Login code is invalid or expired")
+	}
+
+	// receiving the code proves ownership of the email address
+	if !user.IsVerified {
+		err = s.repository.UpdateById(ctx, user.ID, bson.M{"isVerified": true})
+		if err != nil {
+			return nil, eris.Wrap(err, "could not verify user")
+		}
+	}
+
+	// emailed one-time code of risky login would go to the mailbox the user just proved access to, the login is only reported
+	_, err = s.userService.assessLogin(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	request := TokenRequest{Scope: loginCode.Scope}.Authenticated(passport.AmrOTP)
+
+	if user.IsTotpEnabled {
+		return nil, s.userService.requireMfa(ctx, user, request)
+	}
+
+	return s.userService.IssueTokens(ctx, user, request)
+}
+
+// generateLoginCode generates six digit code that is easy to type from the email
+func generateLoginCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
@@ -0,0 +1,61 @@
+package users
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rotisserie/eris"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// errRefreshTokenReused means rotated or revoked refresh token was presented again, so it has leaked
+var errRefreshTokenReused = errors.New("refresh token reused")
+
+type RefreshToken struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	CreatedOn   time.Time          `bson:"createdOn"`
+	ExpiresOn   time.Time          `bson:"expiresOn"`
+	UsedOn      *time.Time         `bson:"usedOn,omitempty"`
+	RevokedOn   *time.Time         `bson:"revokedOn,omitempty"`
+	UserID      primitive.ObjectID `bson:"userId"`
+	FamilyID    primitive.ObjectID `bson:"familyId"`
+	TokenHash   string             `bson:"tokenHash"`
+	ClientID    string             `bson:"clientId,omitempty"`
+	Scope       string             `bson:"scope,omitempty"`
+	AuthTime    time.Time          `bson:"authTime"`
+	AuthMethods []string           `bson:"authMethods,omitempty"`
+	// TokenVersion of the user at issuance, refresh is refused once the user version moves on
+	TokenVersion int `bson:"tokenVersion"`
+}
+
+// NewRefreshToken keeps authentication of the request so it survives rotation
+func NewRefreshToken(user *User, familyID primitive.ObjectID, tokenHash string, request TokenRequest, ttl time.Duration) *RefreshToken {
+	now := time.Now().UTC()
+
+	return &RefreshToken{
+		ID:           primitive.NewObjectID(),
+		CreatedOn:    now,
+		ExpiresOn:    now.Add(ttl),
+		UserID:       user.ID,
+		FamilyID:     familyID,
+		TokenHash:    tokenHash,
+		ClientID:     request.ClientID,
+		Scope:        request.Scope,
+		AuthTime:     request.AuthTime,
+		AuthMethods:  request.AuthMethods,
+		TokenVersion: user.TokenVersion,
+	}
+}
+
+// verify checks the refresh token can still be rotated, errRefreshTokenReused asks for revoking its whole family
+func (t *RefreshToken) verify(now time.Time) error {
+	if t.UsedOn != nil || t.RevokedOn != nil {
+		return errRefreshTokenReused
+	}
+
+	if now.After(t.ExpiresOn) {
+		return eris.New("Refresh token is expired")
+	}
+
+	return nil
+}
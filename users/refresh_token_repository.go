@@ -0,0 +1,92 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RefreshTokenRepository struct {
+	*passport.MongoRepository
+}
+
+func NewRefreshTokenRepository(client *mongo.Client, conf *passport.Config) *RefreshTokenRepository {
+	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "refresh_tokens")
+
+	tokenHashIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	familyIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "familyId", Value: 1}},
+	}
+
+	expirationIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresOn", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	_, err := repository.Collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{tokenHashIndex, familyIndex, expirationIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	return &RefreshTokenRepository{repository}
+}
+
+func (r *RefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*RefreshToken, error) {
+	result := &RefreshToken{}
+
+	err := r.Collection.FindOne(ctx, bson.M{
+		"tokenHash": tokenHash,
+	}).Decode(result)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// MarkUsed flags the token as used, returning false when it was already used or revoked
+func (r *RefreshTokenRepository) MarkUsed(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	filter := bson.M{"_id": id, "usedOn": bson.M{"$exists": false}, "revokedOn": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"usedOn": time.Now().UTC()}}
+
+	ur, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return ur.ModifiedCount > 0, nil
+}
+
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID primitive.ObjectID) error {
+	filter := bson.M{"familyId": familyID, "revokedOn": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revokedOn": time.Now().UTC()}}
+
+	_, err := r.Collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (r *RefreshTokenRepository) DeleteFamily(ctx context.Context, familyID primitive.ObjectID) error {
+	_, err := r.Collection.DeleteMany(ctx, bson.M{"familyId": familyID})
+	return err
+}
+
+func (r *RefreshTokenRepository) RevokeForUser(ctx context.Context, userID primitive.ObjectID) error {
+	filter := bson.M{"userId": userID, "revokedOn": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revokedOn": time.Now().UTC()}}
+
+	_, err := r.Collection.UpdateMany(ctx, filter, update)
+	return err
+}
@@ -0,0 +1,39 @@
+package users
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenVerify(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	used := now.Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		token   RefreshToken
+		reused  bool
+		invalid bool
+	}{
+		{name: "unused", token: RefreshToken{ExpiresOn: now.Add(time.Hour)}},
+		{name: "rotated before", token: RefreshToken{ExpiresOn: now.Add(time.Hour), UsedOn: &used}, reused: true},
+		{name: "revoked family", token: RefreshToken{ExpiresOn: now.Add(time.Hour), RevokedOn: &used}, reused: true},
+		{name: "rotated before and expired since", token: RefreshToken{ExpiresOn: now.Add(-time.Hour), UsedOn: &used}, reused: true},
+		{name: "expired", token: RefreshToken{ExpiresOn: now.Add(-time.Second)}, invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.token.verify(now)
+
+			if errors.Is(err, errRefreshTokenReused) != tt.reused {
+				t.Fatalf("expected reuse %v, got %v", tt.reused, err)
+			}
+
+			if !tt.reused && (err != nil) != tt.invalid {
+				t.Fatalf("expected invalid %v, got %v", tt.invalid, err)
+			}
+		})
+	}
+}
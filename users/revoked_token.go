@@ -0,0 +1,25 @@
+package users
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RevokedToken struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	CreatedOn time.Time          `bson:"createdOn"`
+	ExpiresOn time.Time          `bson:"expiresOn"`
+	TokenID   string             `bson:"jti"`
+	Subject   string             `bson:"sub"`
+}
+
+func NewRevokedToken(tokenID string, subject string, expiresOn time.Time) *RevokedToken {
+	return &RevokedToken{
+		ID:        primitive.NewObjectID(),
+		CreatedOn: time.Now().UTC(),
+		ExpiresOn: expiresOn,
+		TokenID:   tokenID,
+		Subject:   subject,
+	}
+}
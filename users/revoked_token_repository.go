@@ -0,0 +1,53 @@
+package users
+
+import (
+	"context"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RevokedTokenRepository struct {
+	*passport.MongoRepository
+}
+
+func NewRevokedTokenRepository(client *mongo.Client, conf *passport.Config) *RevokedTokenRepository {
+	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "revoked_tokens")
+
+	tokenIdIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "jti", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	expirationIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresOn", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	_, err := repository.Collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{tokenIdIndex, expirationIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	return &RevokedTokenRepository{repository}
+}
+
+func (r *RevokedTokenRepository) Revoke(ctx context.Context, revokedToken *RevokedToken) error {
+	filter := bson.M{"jti": revokedToken.TokenID}
+	update := bson.M{"$setOnInsert": revokedToken}
+
+	opts := options.Update().SetUpsert(true)
+	_, err := r.Collection.UpdateOne(ctx, filter, update, opts)
+	return err
+}
+
+func (r *RevokedTokenRepository) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	count, err := r.Collection.CountDocuments(ctx, bson.M{"jti": tokenID}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
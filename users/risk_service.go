@@ -0,0 +1,218 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/netip"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"github.com/georgi-georgiev/passport/facade"
+	"github.com/georgi-georgiev/passport/pkg/geoip"
+	"github.com/rotisserie/eris"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	RiskSignalNewDevice        = "new_device"
+	RiskSignalNewIPRange       = "new_ip_range"
+	RiskSignalNewCountry       = "new_country"
+	RiskSignalImpossibleTravel = "impossible_travel"
+)
+
+// geoip places addresses only roughly, shorter trips are never reported as impossible travel
+const minTravelDistance = 500.0
+
+// RiskAssessment scores login from the current request against known devices of the user, 0 is no risk and 100 the highest
+type RiskAssessment struct {
+	Score       int
+	Signals     []string
+	Fingerprint string
+	IP          string
+	IPRange     string
+	UserAgent   string
+	Location    geoip.Location
+	// IsFirstLogin is set when the user has no known devices yet, there is nothing to compare with
+	IsFirstLogin bool
+}
+
+func (a *RiskAssessment) HasSignal(signal string) bool {
+	return slices.Contains(a.Signals, signal)
+}
+
+func (a *RiskAssessment) add(signal string, score int) {
+	a.Signals = append(a.Signals, signal)
+	a.Score += score
+
+	if a.Score > 100 {
+		a.Score = 100
+	}
+}
+
+type RiskService struct {
+	notificationFacade *facade.NotificationFacade
+	repository         *KnownDeviceRepository
+	geoip              *geoip.Database
+	conf               *passport.Config
+	log                *zap.Logger
+}
+
+func NewRiskService(notificationFacade *facade.NotificationFacade, repository *KnownDeviceRepository, conf *passport.Config, log *zap.Logger) *RiskService {
+	var database *geoip.Database
+	if conf.Risk.GeoIPPath != "" {
+		var err error
+
+		database, err = geoip.Open(conf.Risk.GeoIPPath)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return &RiskService{notificationFacade: notificationFacade, repository: repository, geoip: database, conf: conf, log: log}
+}
+
+// Assess scores login of the user from the current request, flows can consult it before issuing tokens
+func (s *RiskService) Assess(ctx context.Context, user *User) (*RiskAssessment, error) {
+	assessment := s.describe(ctx)
+
+	devices, err := s.repository.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get known devices")
+	}
+
+	if len(devices) == 0 {
+		assessment.IsFirstLogin = true
+		return assessment, nil
+	}
+
+	isKnownDevice := false
+	isKnownRange := false
+	isKnownCountry := assessment.Location.Country == ""
+
+	for _, device := range devices {
+		isKnownDevice = isKnownDevice || device.Fingerprint == assessment.Fingerprint
+		isKnownRange = isKnownRange || slices.Contains(device.IPRanges, assessment.IPRange)
+		isKnownCountry = isKnownCountry || slices.Contains(device.Countries, assessment.Location.Country)
+	}
+
+	if !isKnownDevice {
+		assessment.add(RiskSignalNewDevice, s.conf.Risk.NewDeviceScore)
+	}
+
+	if !isKnownRange {
+		assessment.add(RiskSignalNewIPRange, s.conf.Risk.NewIPRangeScore)
+	}
+
+	if !isKnownCountry {
+		assessment.add(RiskSignalNewCountry, s.conf.Risk.NewCountryScore)
+	}
+
+	// devices are sorted by last login, the first one is where the user was last seen
+	if s.isImpossibleTravel(devices[0], assessment.Location) {
+		assessment.add(RiskSignalImpossibleTravel, s.conf.Risk.ImpossibleTravelScore)
+	}
+
+	return assessment, nil
+}
+
+func (s *RiskService) isImpossibleTravel(last *KnownDevice, location geoip.Location) bool {
+	if !last.HasCoordinates || !location.HasCoordinates || s.conf.Risk.MaxTravelSpeed <= 0 {
+		return false
+	}
+
+	distance := geoip.Distance(geoip.Location{Latitude: last.LastLatitude, Longitude: last.LastLongitude}, location)
+	if distance < minTravelDistance {
+		return false
+	}
+
+	hours := math.Max(time.Since(last.LastSeenOn).Hours(), 1.0/60)
+
+	return distance/hours > s.conf.Risk.MaxTravelSpeed
+}
+
+// describe identifies device, network and location of the current request
+func (s *RiskService) describe(ctx context.Context) *RiskAssessment {
+	info := passport.RequestInfoFrom(ctx)
+
+	// device id sent by the client survives browser updates, user agent is the fallback
+	fingerprint := "ua:" + info.UserAgent
+	if info.DeviceID != "" {
+		fingerprint = "device:" + info.DeviceID
+	}
+
+	assessment := &RiskAssessment{
+		Signals:     make([]string, 0),
+		Fingerprint: passport.HashToken(fingerprint),
+		IP:          info.IP,
+		IPRange:     ipRange(info.IP),
+		UserAgent:   info.UserAgent,
+	}
+
+	if s.geoip != nil {
+		assessment.Location, _ = s.geoip.Lookup(info.IP)
+	}
+
+	return assessment
+}
+
+// ipRange returns the network the address belongs to, /24 for IPv4 and /48 for IPv6
+func ipRange(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+
+	addr = addr.Unmap()
+
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ip
+	}
+
+	return prefix.String()
+}
+
+// RememberDevice records the current device as known once the user completed login from it
+func (s *RiskService) RememberDevice(ctx context.Context, user *User) error {
+	err := s.repository.Remember(ctx, user.ID, s.describe(ctx), s.conf.Risk.DeviceTTL)
+	if err != nil {
+		return eris.Wrap(err, "could not remember device")
+	}
+
+	return nil
+}
+
+// RequiresChallenge reports whether the login is risky enough to ask for email one-time code
+func (s *RiskService) RequiresChallenge(assessment *RiskAssessment) bool {
+	return s.conf.Risk.ChallengeScore > 0 && assessment.Score >= s.conf.Risk.ChallengeScore
+}
+
+// Alert lets the user know about login from unfamiliar device or place, the credentials were already verified
+func (s *RiskService) Alert(ctx context.Context, user *User, assessment *RiskAssessment) {
+	if assessment.IsFirstLogin || s.conf.Risk.AlertScore == 0 || assessment.Score < s.conf.Risk.AlertScore {
+		return
+	}
+
+	s.log.Info("risky login", zap.String("userId", user.ID.Hex()), zap.Int("score", assessment.Score), zap.Strings("signals", assessment.Signals))
+
+	place := assessment.IP
+	if assessment.Location.Country != "" {
+		place = fmt.Sprintf("%s, %s", assessment.IP, assessment.Location.Country)
+	}
+
+	s.notificationFacade.Publish(ctx, "email", facade.Message{
+		Topic:     "identity",
+		Header:    "New Sign In",
+		Body:      fmt.Sprintf("Your account was signed in to from a new device or location (%s, %s) at %s. If this was not you, reset your password immediately.", assessment.UserAgent, place, time.Now().UTC().Format(time.RFC1123)),
+		Params:    map[string]string{"email": user.Email},
+		Meta:      nil,
+		Timestamp: time.Now().Unix(),
+	}, user.ID.Hex())
+}
@@ -0,0 +1,38 @@
+package users
+
+import (
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is one sign in of the user, its id is also the refresh token family id and the sid claim of access tokens
+type Session struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	CreatedOn   time.Time          `bson:"createdOn"`
+	LastUsedOn  time.Time          `bson:"lastUsedOn"`
+	ExpiresOn   time.Time          `bson:"expiresOn"`
+	RevokedOn   *time.Time         `bson:"revokedOn,omitempty"`
+	UserID      primitive.ObjectID `bson:"userId"`
+	ClientID    string             `bson:"clientId,omitempty"`
+	UserAgent   string             `bson:"userAgent,omitempty"`
+	IP          string             `bson:"ip,omitempty"`
+	AuthMethods []string           `bson:"authMethods,omitempty"`
+}
+
+func NewSession(userID primitive.ObjectID, request TokenRequest, info passport.RequestInfo, ttl time.Duration) *Session {
+	now := time.Now().UTC()
+
+	return &Session{
+		ID:          primitive.NewObjectID(),
+		CreatedOn:   now,
+		LastUsedOn:  now,
+		ExpiresOn:   now.Add(ttl),
+		UserID:      userID,
+		ClientID:    request.ClientID,
+		UserAgent:   info.UserAgent,
+		IP:          info.IP,
+		AuthMethods: request.AuthMethods,
+	}
+}
@@ -0,0 +1,145 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/georgi-georgiev/blunder"
+	"github.com/georgi-georgiev/passport/responses"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetSessionsHandler godoc
+// @Summary Get sessions
+// @Description Lists active sessions of the user, available to the user and admins
+// @Tags identity
+// @Produce  json
+// @Security OAuth2Application
+// @Param userId path string true "user id"
+// @Success 200 {array} responses.SessionResponse
+// @Failure      403  {object}  blunder.HTTPErrorResponse
+// @Router /users/{userId}/sessions [get]
+func (h *UserHandlers) GetSessions(c *gin.Context) {
+	userId, ok := h.sessionOwner(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessionService.GetSessions(c.Request.Context(), userId)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	response := make([]responses.SessionResponse, 0)
+	for _, session := range sessions {
+		response = append(response, MapToSessionResponse(session, c.GetString("sessionId")))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteSessionHandler godoc
+// @Summary Delete session
+// @Description Signs the user out of one session, its tokens stop working immediately
+// @Tags identity
+// @Security OAuth2Application
+// @Param userId path string true "user id"
+// @Param sessionId path string true "session id"
+// @Success 204
+// @Failure      404  {object}  blunder.HTTPErrorResponse
+// @Router /users/{userId}/sessions/{sessionId} [delete]
+func (h *UserHandlers) DeleteSession(c *gin.Context) {
+	userId, ok := h.sessionOwner(c)
+	if !ok {
+		return
+	}
+
+	sessionId, err := primitive.ObjectIDFromHex(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, blunder.BadRequest())
+		return
+	}
+
+	isRevoked, err := h.sessionService.Revoke(c.Request.Context(), userId, sessionId)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	if !isRevoked {
+		c.JSON(http.StatusNotFound, blunder.NotFound())
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteSessionsHandler godoc
+// @Summary Sign out everywhere
+// @Description Signs the user out of every session
+// @Tags identity
+// @Security OAuth2Application
+// @Param userId path string true "user id"
+// @Success 204
+// @Router /users/{userId}/sessions [delete]
+func (h *UserHandlers) DeleteSessions(c *gin.Context) {
+	userId, ok := h.sessionOwner(c)
+	if !ok {
+		return
+	}
+
+	// bumping token version also stops access tokens that are not bound to any of the sessions
+	err := h.userService.RevokeAllTokens(c.Request.Context(), userId)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// sessionOwner returns user whose sessions are managed, only the user and admins may manage them
+func (h *UserHandlers) sessionOwner(c *gin.Context) (primitive.ObjectID, bool) {
+	userId, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, blunder.BadRequest())
+		return primitive.NilObjectID, false
+	}
+
+	if c.GetString("userId") == userId.Hex() {
+		return userId, true
+	}
+
+	roleId, err := primitive.ObjectIDFromHex(c.GetString("roleId"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, blunder.Forbidden())
+		return primitive.NilObjectID, false
+	}
+
+	role, err := h.roleService.GetById(c.Request.Context(), roleId)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return primitive.NilObjectID, false
+	}
+
+	if role == nil || role.Name != "admin" {
+		c.JSON(http.StatusForbidden, blunder.Forbidden())
+		return primitive.NilObjectID, false
+	}
+
+	return userId, true
+}
+
+func MapToSessionResponse(session *Session, currentSessionId string) responses.SessionResponse {
+	return responses.SessionResponse{
+		ID:          session.ID.Hex(),
+		ClientID:    session.ClientID,
+		UserAgent:   session.UserAgent,
+		IP:          session.IP,
+		AuthMethods: session.AuthMethods,
+		CreatedOn:   session.CreatedOn,
+		LastUsedOn:  session.LastUsedOn,
+		Current:     session.ID.Hex() == currentSessionId,
+	}
+}
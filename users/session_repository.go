@@ -0,0 +1,117 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SessionRepository struct {
+	*passport.MongoRepository
+}
+
+func NewSessionRepository(client *mongo.Client, conf *passport.Config) *SessionRepository {
+	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "sessions")
+
+	userIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "userId", Value: 1}, {Key: "lastUsedOn", Value: -1}},
+	}
+
+	expirationIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresOn", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	_, err := repository.Collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{userIndex, expirationIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	return &SessionRepository{repository}
+}
+
+func activeSessionFilter() bson.M {
+	return bson.M{"revokedOn": bson.M{"$exists": false}, "expiresOn": bson.M{"$gt": time.Now().UTC()}}
+}
+
+// GetActiveByUserID lists sessions of the user, most recently used first
+func (r *SessionRepository) GetActiveByUserID(ctx context.Context, userID primitive.ObjectID) ([]*Session, error) {
+	filter := activeSessionFilter()
+	filter["userId"] = userID
+
+	opts := options.Find().SetSort(bson.D{{Key: "lastUsedOn", Value: -1}})
+
+	cursor, err := r.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Session, 0)
+
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SessionRepository) IsActive(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	filter := activeSessionFilter()
+	filter["_id"] = id
+
+	count, err := r.Collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// Touch records use of the session on refresh, extending it by ttl
+func (r *SessionRepository) Touch(ctx context.Context, id primitive.ObjectID, ttl time.Duration) (bool, error) {
+	filter := activeSessionFilter()
+	filter["_id"] = id
+
+	now := time.Now().UTC()
+	update := bson.M{"$set": bson.M{"lastUsedOn": now, "expiresOn": now.Add(ttl)}}
+
+	ur, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return ur.ModifiedCount > 0, nil
+}
+
+// Revoke ends active session of the user, returning false when there is no such session
+func (r *SessionRepository) Revoke(ctx context.Context, userID primitive.ObjectID, id primitive.ObjectID) (bool, error) {
+	filter := activeSessionFilter()
+	filter["_id"] = id
+	filter["userId"] = userID
+
+	ur, err := r.Collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revokedOn": time.Now().UTC()}})
+	if err != nil {
+		return false, err
+	}
+
+	return ur.ModifiedCount > 0, nil
+}
+
+// RevokeAllForUser ends every active session of the user
+func (r *SessionRepository) RevokeAllForUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	filter := activeSessionFilter()
+	filter["userId"] = userID
+
+	ur, err := r.Collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedOn": time.Now().UTC()}})
+	if err != nil {
+		return 0, err
+	}
+
+	return ur.ModifiedCount, nil
+}
@@ -0,0 +1,103 @@
+package users
+
+import (
+	"context"
+
+	"github.com/georgi-georgiev/passport"
+	"github.com/rotisserie/eris"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type SessionService struct {
+	repository             *SessionRepository
+	refreshTokenRepository *RefreshTokenRepository
+	conf                   *passport.Config
+	log                    *zap.Logger
+}
+
+func NewSessionService(repository *SessionRepository, refreshTokenRepository *RefreshTokenRepository, conf *passport.Config, log *zap.Logger) *SessionService {
+	return &SessionService{repository: repository, refreshTokenRepository: refreshTokenRepository, conf: conf, log: log}
+}
+
+// Create starts session for tokens being issued, it lives as long as refresh tokens of its family
+func (s *SessionService) Create(ctx context.Context, userID primitive.ObjectID, request TokenRequest) (*Session, error) {
+	session := NewSession(userID, request, passport.RequestInfoFrom(ctx), s.conf.Token.RefreshTokenTTL)
+
+	_, err := s.repository.Create(ctx, session)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not store session")
+	}
+
+	return session, nil
+}
+
+// Touch marks the session used by refresh, returning false once the session was revoked or expired
+func (s *SessionService) Touch(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	isTouched, err := s.repository.Touch(ctx, id, s.conf.Token.RefreshTokenTTL)
+	if err != nil {
+		return false, eris.Wrap(err, "could not update session")
+	}
+
+	return isTouched, nil
+}
+
+// IsActive reports whether tokens bound to the session are still accepted, user tokens without session are not
+func (s *SessionService) IsActive(ctx context.Context, sid string) (bool, error) {
+	id, err := primitive.ObjectIDFromHex(sid)
+	if err != nil {
+		return false, nil
+	}
+
+	isActive, err := s.repository.IsActive(ctx, id)
+	if err != nil {
+		return false, eris.Wrap(err, "could not check session")
+	}
+
+	return isActive, nil
+}
+
+func (s *SessionService) GetSessions(ctx context.Context, userID primitive.ObjectID) ([]*Session, error) {
+	sessions, err := s.repository.GetActiveByUserID(ctx, userID)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get sessions")
+	}
+
+	return sessions, nil
+}
+
+// Revoke signs the user out of one session, access tokens of the session stop working immediately
+func (s *SessionService) Revoke(ctx context.Context, userID primitive.ObjectID, id primitive.ObjectID) (bool, error) {
+	isRevoked, err := s.repository.Revoke(ctx, userID, id)
+	if err != nil {
+		return false, eris.Wrap(err, "could not revoke session")
+	}
+
+	if !isRevoked {
+		return false, nil
+	}
+
+	err = s.refreshTokenRepository.RevokeFamily(ctx, id)
+	if err != nil {
+		return false, eris.Wrap(err, "could not revoke refresh token family")
+	}
+
+	return true, nil
+}
+
+// RevokeAll signs the user out everywhere
+func (s *SessionService) RevokeAll(ctx context.Context, userID primitive.ObjectID) error {
+	count, err := s.repository.RevokeAllForUser(ctx, userID)
+	if err != nil {
+		return eris.Wrap(err, "could not revoke sessions")
+	}
+
+	err = s.refreshTokenRepository.RevokeForUser(ctx, userID)
+	if err != nil {
+		return eris.Wrap(err, "could not revoke refresh tokens")
+	}
+
+	s.log.Info("user signed out everywhere", zap.String("userId", userID.Hex()), zap.Int64("sessions", count))
+
+	return nil
+}
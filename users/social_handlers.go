@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/georgi-georgiev/blunder"
+	"github.com/georgi-georgiev/passport"
 	"github.com/georgi-georgiev/passport/responses"
 	"github.com/gin-gonic/gin"
 	fb "github.com/huandu/facebook/v2"
@@ -40,20 +41,17 @@ func (h *UserHandlers) FacebookCallback(c *gin.Context) {
 	}
 
 	if existingUser != nil {
-
-		token, exp, err := h.userService.IssueAccessToken(existingUser)
-		if err != nil {
-			h.blunder.GinAdd(c, err)
+		tokens, err := h.userService.SocialLogin(c.Request.Context(), existingUser)
+		if h.mfaRequired(c, err) {
 			return
 		}
 
-		refreshToken, err := h.userService.IssueRefreshToken(existingUser)
 		if err != nil {
 			h.blunder.GinAdd(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, responses.CreateUserResponse{ID: existingUser.ID.Hex(), TokenType: "Bearer", AccessToken: token, RefreshToken: refreshToken, ExpiresIn: exp})
+		c.JSON(http.StatusOK, responses.CreateUserResponse{ID: existingUser.ID.Hex(), TokenType: "Bearer", AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken, ExpiresIn: tokens.ExpiresIn})
 		return
 	}
 
@@ -63,19 +61,15 @@ func (h *UserHandlers) FacebookCallback(c *gin.Context) {
 		return
 	}
 
-	token, exp, err := h.userService.IssueAccessToken(user)
-	if err != nil {
-		h.blunder.GinAdd(c, err)
-		return
-	}
+	request := TokenRequest{}.Authenticated(passport.AmrSocial)
 
-	refreshToken, err := h.userService.IssueRefreshToken(user)
+	tokens, err := h.userService.IssueTokens(c.Request.Context(), user, request)
 	if err != nil {
 		h.blunder.GinAdd(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, responses.CreateUserResponse{ID: existingUser.ID.Hex(), TokenType: "Bearer", AccessToken: token, RefreshToken: refreshToken, ExpiresIn: exp})
+	c.JSON(http.StatusCreated, responses.CreateUserResponse{ID: user.ID.Hex(), TokenType: "Bearer", AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken, ExpiresIn: tokens.ExpiresIn})
 }
 
 func verifyFacebookToken(accessToken string) (*FacebookUser, error) {
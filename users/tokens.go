@@ -0,0 +1,76 @@
+package users
+
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// TokenRequest describes the party and the context tokens are issued for
+type TokenRequest struct {
+	ClientID       string
+	Scope          string
+	Nonce          string
+	AuthTime       time.Time
+	AuthMethods    []string
+	IP             string
+	SessionID      string
+	NoRefreshToken bool
+}
+
+// Authenticated returns copy of the request recording authentication performed now with the methods
+func (r TokenRequest) Authenticated(methods ...string) TokenRequest {
+	r.AuthTime = time.Now().UTC()
+	r.AuthMethods = methods
+
+	return r
+}
+
+// HasScope reports whether scope is among the space delimited requested scopes
+func (r TokenRequest) HasScope(scope string) bool {
+	return slices.Contains(strings.Fields(r.Scope), scope)
+}
+
+type Tokens struct {
+	AccessToken     string
+	RefreshToken    string
+	IDToken         string
+	Scope           string
+	ExpiresIn       int64
+	IssuedTokenType string
+}
+
+const (
+	// TokenTypeAccessToken identifies access tokens issued by passport in token exchange
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	// TokenTypeUserID identifies subject given by user id, used for impersonation
+	TokenTypeUserID = "urn:passport:params:oauth:token-type:user_id"
+)
+
+// TokenExchangeRequest holds parameters of RFC 8693 token exchange
+type TokenExchangeRequest struct {
+	SubjectToken       string
+	SubjectTokenType   string
+	ActorToken         string
+	ActorTokenType     string
+	RequestedTokenType string
+	Audience           string
+	Scope              string
+	Rights             []string
+}
+
+// AuthorizationRequest holds parameters of the authorization endpoint
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	// authentication of the resource owner, taken from the session rather than request parameters
+	AuthTime    time.Time
+	AuthMethods []string
+}
@@ -21,6 +21,17 @@ type User struct {
 	ResettingCode     string               `bson:"resettingCode,omitempty"`
 	Role              primitive.ObjectID   `bson:"role,omitempty"`
 	Rights            []primitive.ObjectID `bson:"rights,omitempty"`
+	TotpSecret        string               `bson:"totpSecret,omitempty"`
+	IsTotpEnabled     bool                 `bson:"isTotpEnabled"`
+	TotpLastCounter   int64                `bson:"totpLastCounter,omitempty"`
+	MfaRecoveryCodes  []string             `bson:"mfaRecoveryCodes,omitempty"`
+	// TokenVersion is bumped when credentials or privileges change, tokens issued with older version are rejected
+	TokenVersion int `bson:"tokenVersion"`
+}
+
+// HasTokenVersion reports whether tokens issued with the version are still accepted
+func (u *User) HasTokenVersion(version int) bool {
+	return u.TokenVersion == version
 }
 
 func NewUser(verificationToken string, username string, email string, passwordHash string, role *permissions.Role, rights []*permissions.Right) *User {
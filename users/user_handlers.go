@@ -1,10 +1,15 @@
 package users
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/georgi-georgiev/blunder"
+	"github.com/georgi-georgiev/passport"
+	"github.com/georgi-georgiev/passport/clients"
 	"github.com/georgi-georgiev/passport/payloads"
 	"github.com/georgi-georgiev/passport/permissions"
 	"github.com/georgi-georgiev/passport/responses"
@@ -14,15 +19,22 @@ import (
 )
 
 type UserHandlers struct {
-	userService  *UserService
-	roleService  *permissions.RoleService
-	rightService *permissions.RightService
-	log          *zap.Logger
-	blunder      *blunder.Blunder
+	userService         *UserService
+	oauthService        *OAuthService
+	mfaService          *MfaService
+	webAuthnService     *WebAuthnService
+	passwordlessService *PasswordlessService
+	sessionService      *SessionService
+	clientService       *clients.ClientService
+	keyManager          *passport.KeyManager
+	roleService         *permissions.RoleService
+	rightService        *permissions.RightService
+	log                 *zap.Logger
+	blunder             *blunder.Blunder
 }
 
-func NewUserHandlers(userService *UserService, roleService *permissions.RoleService, rightService *permissions.RightService, log *zap.Logger, blunder *blunder.Blunder) *UserHandlers {
-	return &UserHandlers{userService: userService, roleService: roleService, rightService: rightService, log: log, blunder: blunder}
+func NewUserHandlers(userService *UserService, oauthService *OAuthService, mfaService *MfaService, webAuthnService *WebAuthnService, passwordlessService *PasswordlessService, sessionService *SessionService, clientService *clients.ClientService, keyManager *passport.KeyManager, roleService *permissions.RoleService, rightService *permissions.RightService, log *zap.Logger, blunder *blunder.Blunder) *UserHandlers {
+	return &UserHandlers{userService: userService, oauthService: oauthService, mfaService: mfaService, webAuthnService: webAuthnService, passwordlessService: passwordlessService, sessionService: sessionService, clientService: clientService, keyManager: keyManager, roleService: roleService, rightService: rightService, log: log, blunder: blunder}
 }
 
 // CreateUserHandler godoc
@@ -61,23 +73,19 @@ func (h *UserHandlers) CreateUser(c *gin.Context) {
 
 	user, err := h.userService.CreateUser(c.Request.Context(), payload.Username, payload.Email, payload.Password, payload.Role, false, payload.Rights)
 	if err != nil {
-		h.blunder.GinAdd(c, err)
+		h.addErrors(c, err)
 		return
 	}
 
-	token, exp, err := h.userService.IssueAccessToken(user)
-	if err != nil {
-		h.blunder.GinAdd(c, err)
-		return
-	}
+	request := TokenRequest{}.Authenticated(passport.AmrPassword)
 
-	refreshToken, err := h.userService.IssueRefreshToken(user)
+	tokens, err := h.userService.IssueTokens(c.Request.Context(), user, request)
 	if err != nil {
 		h.blunder.GinAdd(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, responses.CreateUserResponse{ID: user.ID.Hex(), TokenType: "Bearer", AccessToken: token, RefreshToken: refreshToken, ExpiresIn: exp})
+	c.JSON(http.StatusCreated, responses.CreateUserResponse{ID: user.ID.Hex(), TokenType: "Bearer", AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken, ExpiresIn: tokens.ExpiresIn})
 }
 
 // VerifyEmailHandler godoc
@@ -135,23 +143,19 @@ func (h *UserHandlers) CreateAdmin(c *gin.Context) {
 
 	user, err := h.userService.CreateUser(c.Request.Context(), payload.Username, payload.Email, payload.Password, payload.Role, true, payload.Rights)
 	if err != nil {
-		h.blunder.GinAdd(c, err)
+		h.addErrors(c, err)
 		return
 	}
 
-	token, exp, err := h.userService.IssueAccessToken(user)
-	if err != nil {
-		h.blunder.GinAdd(c, err)
-		return
-	}
+	request := TokenRequest{}.Authenticated(passport.AmrPassword)
 
-	refreshToken, err := h.userService.IssueRefreshToken(user)
+	tokens, err := h.userService.IssueTokens(c.Request.Context(), user, request)
 	if err != nil {
 		h.blunder.GinAdd(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, responses.CreateUserResponse{ID: user.ID.Hex(), TokenType: "Bearer", AccessToken: token, RefreshToken: refreshToken, ExpiresIn: exp})
+	c.JSON(http.StatusCreated, responses.CreateUserResponse{ID: user.ID.Hex(), TokenType: "Bearer", AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken, ExpiresIn: tokens.ExpiresIn})
 }
 
 // UpdateUserHandler godoc
@@ -188,7 +192,7 @@ func (h *UserHandlers) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.UpdateUser(c.Request.Context(), userId, payload.Email, payload.Username, payload.IsActive, payload.ShouldChangePassword)
+	user, err := h.userService.UpdateUser(c.Request.Context(), userId, payload.Email, payload.Username, payload.IsActive, payload.ShouldChangePassword, payload.Role, payload.Rights)
 	if err != nil {
 		h.blunder.GinAdd(c, err)
 		return
@@ -216,6 +220,7 @@ func (h *UserHandlers) UpdateUser(c *gin.Context) {
 // @Produce  json
 // @Security OAuth2Application
 // @Param userID path int true "1"
+// @Failure      401  {object}  responses.InsufficientAuthenticationResponse
 // @Router /users/{userId} [delete]
 func (h *UserHandlers) DeleteUser(c *gin.Context) {
 	userIDParam := c.Param("userId")
@@ -244,6 +249,38 @@ func (h *UserHandlers) DeleteUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{})
 }
 
+// UnlockUserHandler godoc
+// @Summary Unlock user
+// @Description lift login lockout caused by failed attempts
+// @Tags identity
+// @Accept  json
+// @Produce  json
+// @Security OAuth2Application
+// @Param userId path string true "user id"
+// @Success 204
+// @Failure      404  {object}  blunder.HTTPErrorResponse
+// @Router /users/{userId}/unlock [post]
+func (h *UserHandlers) UnlockUser(c *gin.Context) {
+	userId, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, blunder.BadRequest())
+		return
+	}
+
+	isUnlocked, err := h.userService.UnlockUser(c.Request.Context(), userId)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	if !isUnlocked {
+		c.JSON(http.StatusNotFound, blunder.NotFound())
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
 // GetUsersHandler godoc
 // @Summary Get users
 // @Description get users
@@ -324,29 +361,49 @@ func (h *UserHandlers) GetUserById(c *gin.Context) {
 // @Produce  json
 // @Security BasicAuth
 // @Param type query string false "refresh_token"
+// @Param scope query string false "openid email profile"
+// @Param nonce query string false "nonce"
+// @Param grant_type formData string false "authorization_code or refresh_token"
 // @Success 200 {object} TokenResponse
+// @Failure      429  {object}  blunder.HTTPErrorResponse
 // @Router /token [post]
 func (h *UserHandlers) GetToken(c *gin.Context) {
 
+	grantType := c.PostForm("grant_type")
+	if grantType != "" {
+		h.oauthToken(c, grantType)
+		return
+	}
+
 	t := c.Request.URL.Query().Get("type")
 	if t == "refresh_token" {
-		refToken := strings.Split(c.Request.Header.Get("Authorization"), " ")[1]
-		token, exp, err := h.userService.RefreshToken(c.Request.Context(), refToken)
+		headerItems := strings.Split(c.Request.Header.Get("Authorization"), " ")
+		if len(headerItems) < 2 {
+			c.JSON(http.StatusBadRequest, blunder.BadRequest())
+			return
+		}
+
+		tokens, err := h.userService.RefreshToken(c.Request.Context(), headerItems[1], "")
 		if err != nil {
 			h.blunder.GinAdd(c, err)
 		} else {
-			c.JSON(http.StatusOK, responses.TokenResponse{TokenType: "Bearer", AccessToken: token, RefreshToken: refToken, ExpiresIn: exp})
+			c.JSON(http.StatusOK, responses.TokenResponse{TokenType: "Bearer", AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken, ExpiresIn: tokens.ExpiresIn})
 		}
 		return
 	}
 
 	u, p, ok := c.Request.BasicAuth()
 	if ok {
-		accessToken, refreshToken, exp, err := h.userService.BasicAuthToken(c.Request.Context(), u, p)
+		request := TokenRequest{Scope: c.Query("scope"), Nonce: c.Query("nonce"), IP: c.ClientIP()}
+		tokens, err := h.userService.BasicAuthToken(c.Request.Context(), u, p, request)
+		if h.mfaRequired(c, err) || h.lockedOut(c, err) {
+			return
+		}
+
 		if err != nil {
 			h.blunder.GinAdd(c, err)
 		} else {
-			c.JSON(http.StatusOK, responses.TokenResponse{TokenType: "Bearer", AccessToken: accessToken, RefreshToken: refreshToken, ExpiresIn: exp})
+			c.JSON(http.StatusOK, responses.TokenResponse{TokenType: "Bearer", AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken, IDToken: tokens.IDToken, ExpiresIn: tokens.ExpiresIn})
 		}
 		return
 	}
@@ -354,6 +411,103 @@ func (h *UserHandlers) GetToken(c *gin.Context) {
 	c.JSON(http.StatusBadRequest, blunder.BadRequest())
 }
 
+// RevokeTokenHandler godoc
+// @Summary Revoke token
+// @Description revoke access or refresh token issued to the authenticated client (RFC 7009), tokens issued without client are revoked with logout
+// @Tags identity
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Security BasicAuth
+// @Param token formData string true "token"
+// @Param token_type_hint formData string false "access_token or refresh_token"
+// @Param client_id formData string false "client id"
+// @Param client_secret formData string false "client secret"
+// @Router /revoke [post]
+func (h *UserHandlers) RevokeToken(c *gin.Context) {
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		h.oauthError(c, err)
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, blunder.BadRequest())
+		return
+	}
+
+	err = h.userService.RevokeToken(c.Request.Context(), token, c.PostForm("token_type_hint"), client.ClientID)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// LogoutHandler godoc
+// @Summary Logout
+// @Description revoke current access token and optionally the refresh token
+// @Tags identity
+// @Accept  json
+// @Produce  json
+// @Security OAuth2Application
+// @Param data body LogoutPayload false "data"
+// @Router /logout [post]
+func (h *UserHandlers) Logout(c *gin.Context) {
+	headerItems := strings.Split(c.Request.Header.Get("Authorization"), " ")
+	if len(headerItems) < 2 {
+		c.JSON(http.StatusUnauthorized, blunder.Unauthorized())
+		return
+	}
+
+	var payload payloads.LogoutPayload
+
+	if c.Request.ContentLength > 0 {
+		errors := h.blunder.BindJson(c.Request, &payload)
+		if errors != nil {
+			for _, err := range errors {
+				h.blunder.GinAdd(c, err)
+			}
+			return
+		}
+	}
+
+	err := h.userService.Logout(c.Request.Context(), headerItems[1], payload.RefreshToken)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// IntrospectTokenHandler godoc
+// @Summary Introspect token
+// @Description introspect token state (RFC 7662)
+// @Tags identity
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Security OAuth2Application
+// @Param token formData string true "token"
+// @Success 200 {object} responses.IntrospectionResponse
+// @Router /introspect [post]
+func (h *UserHandlers) IntrospectToken(c *gin.Context) {
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, blunder.BadRequest())
+		return
+	}
+
+	response, err := h.userService.Introspect(c.Request.Context(), token)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, *response)
+}
+
 // GetJWKSHandler godoc
 // @Summary Get jwks
 // @Description get jwks
@@ -364,22 +518,18 @@ func (h *UserHandlers) GetToken(c *gin.Context) {
 // @Success 200 {object} responses.Jwks
 // @Router /.well-known/jwks.json [get]
 func (h *UserHandlers) JWKS(c *gin.Context) {
-	key, err := h.userService.GetPublicKey()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, blunder.InternalServerError())
-		return
+	jwks := responses.Jwks{
+		Keys: []responses.JSONWebKey{},
 	}
 
-	jwk := responses.JSONWebKey{
-		Kty: "RSA",
-		Kid: "example-key",
-		Use: "sig",
-		N:   key.N,
-		E:   key.E,
-	}
+	for _, key := range h.keyManager.VerificationKeys() {
+		jwk, err := key.JWK()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, blunder.InternalServerError())
+			return
+		}
 
-	jwks := responses.Jwks{
-		Keys: []responses.JSONWebKey{jwk},
+		jwks.Keys = append(jwks.Keys, jwk)
 	}
 
 	c.JSON(http.StatusOK, jwks)
@@ -456,9 +606,34 @@ func (h *UserHandlers) ResetPassword(c *gin.Context) {
 
 	err := h.userService.ResetPassword(c.Request.Context(), payload.Email, payload.Code, payload.Password)
 	if err != nil {
-		h.blunder.GinAdd(c, err)
+		h.addErrors(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{})
 }
+
+// lockedOut writes too many requests response telling the client when login may be retried
+func (h *UserHandlers) lockedOut(c *gin.Context, err error) bool {
+	var lockedErr *LockedOutError
+	if !errors.As(err, &lockedErr) {
+		return false
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+	c.Status(http.StatusTooManyRequests)
+	return true
+}
+
+// addErrors reports each broken password rule as separate error, other errors are added as they are
+func (h *UserHandlers) addErrors(c *gin.Context, err error) {
+	var policyErr *passport.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	for _, violation := range policyErr.Violations {
+		h.blunder.GinAdd(c, violation)
+	}
+}
@@ -18,7 +18,7 @@ type UserRepository struct {
 	*passport.MongoRepository
 }
 
-func NewUserRepository(client *mongo.Client, conf *passport.Config, roleRepository *permissions.RoleRepository) *UserRepository {
+func NewUserRepository(client *mongo.Client, conf *passport.Config, roleRepository *permissions.RoleRepository, passwordHasher *passport.PasswordHasher) *UserRepository {
 	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "users")
 
 	usernameIndex := mongo.IndexModel{
@@ -36,7 +36,7 @@ func NewUserRepository(client *mongo.Client, conf *passport.Config, roleReposito
 		panic(err)
 	}
 
-	hashedPassword, err := passport.Hash("admin")
+	hashedPassword, err := passwordHasher.Hash("admin")
 	if err != nil {
 		panic(err)
 	}
@@ -134,11 +134,16 @@ func (r *UserRepository) GetAll(ctx context.Context) ([]*User, error) {
 	return users, nil
 }
 
+// Update writes fields editable by admins, token version is changed only with IncrementTokenVersion
 func (r *UserRepository) Update(ctx context.Context, u *User) error {
 
 	updateBody := bson.M{}
 	updateBody["email"] = u.Email
 	updateBody["username"] = u.Username
+	updateBody["isActive"] = u.IsActive
+	updateBody["role"] = u.Role
+	updateBody["rights"] = u.Rights
+	updateBody["updatedOn"] = u.UpdatedOn
 
 	return r.UpdateById(ctx, u.ID, updateBody)
 }
@@ -159,6 +164,66 @@ func (r *UserRepository) GetResettingCode(ctx context.Context, id primitive.Obje
 	return r.GetStringFieldForId(ctx, id, "resettingCode")
 }
 
+// SetTotpSecret stores encrypted secret of pending enrollment, second factor stays disabled until confirmed
+func (r *UserRepository) SetTotpSecret(ctx context.Context, id primitive.ObjectID, encryptedSecret string) error {
+	update := bson.M{"$set": bson.M{"totpSecret": encryptedSecret, "isTotpEnabled": false}, "$unset": bson.M{"totpLastCounter": ""}}
+	_, err := r.Collection.UpdateByID(ctx, id, update)
+	return err
+}
+
+func (r *UserRepository) EnableTotp(ctx context.Context, id primitive.ObjectID, counter int64) error {
+	return r.UpdateById(ctx, id, bson.M{"isTotpEnabled": true, "totpLastCounter": counter})
+}
+
+func (r *UserRepository) DisableTotp(ctx context.Context, id primitive.ObjectID) error {
+	update := bson.M{"$set": bson.M{"isTotpEnabled": false}, "$unset": bson.M{"totpSecret": "", "totpLastCounter": "", "mfaRecoveryCodes": ""}}
+	_, err := r.Collection.UpdateByID(ctx, id, update)
+	return err
+}
+
+// UseTotpCounter records time step of accepted code, returning false when the same or later step was already used
+func (r *UserRepository) UseTotpCounter(ctx context.Context, id primitive.ObjectID, counter int64) (bool, error) {
+	filter := bson.M{"_id": id, "totpLastCounter": bson.M{"$lt": counter}}
+	update := bson.M{"$set": bson.M{"totpLastCounter": counter}}
+
+	ur, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return ur.ModifiedCount > 0, nil
+}
+
+// SetMfaRecoveryCodes replaces hashes of mfa recovery codes
+func (r *UserRepository) SetMfaRecoveryCodes(ctx context.Context, id primitive.ObjectID, codeHashes []string) error {
+	return r.UpdateById(ctx, id, bson.M{"mfaRecoveryCodes": codeHashes})
+}
+
+// UseMfaRecoveryCode removes hash of consumed mfa recovery code, returning false when it was already consumed
+func (r *UserRepository) UseMfaRecoveryCode(ctx context.Context, id primitive.ObjectID, codeHash string) (bool, error) {
+	filter := bson.M{"_id": id, "mfaRecoveryCodes": codeHash}
+	update := bson.M{"$pull": bson.M{"mfaRecoveryCodes": codeHash}}
+
+	ur, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return ur.ModifiedCount > 0, nil
+}
+
 func (r *UserRepository) ResetPassword(ctx context.Context, id primitive.ObjectID, passwordHash string) error {
 	return r.SetFieldAndWipeOtherForId(ctx, id, "password", passwordHash, "resettingCode")
 }
+
+// IncrementTokenVersion invalidates every token issued to the user so far
+func (r *UserRepository) IncrementTokenVersion(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"tokenVersion": 1}})
+	return err
+}
+
+// UpdatePasswordHash replaces the hash only while it is still the old one, so concurrent password reset is not overwritten
+func (r *UserRepository) UpdatePasswordHash(ctx context.Context, id primitive.ObjectID, oldHash string, newHash string) error {
+	_, err := r.Collection.UpdateOne(ctx, bson.M{"_id": id, "password": oldHash}, bson.M{"$set": bson.M{"password": newHash}})
+	return err
+}
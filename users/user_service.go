@@ -3,14 +3,17 @@ package users
 import (
 	"context"
 	"crypto/rand"
-	"crypto/rsa"
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"os"
+	"strconv"
+	"strings"
 
 	"time"
 
 	"github.com/georgi-georgiev/passport"
+	"github.com/georgi-georgiev/passport/audit"
+	"github.com/georgi-georgiev/passport/clients"
 	"github.com/georgi-georgiev/passport/facade"
 	"github.com/georgi-georgiev/passport/permissions"
 	"github.com/georgi-georgiev/passport/responses"
@@ -19,19 +22,31 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
 )
 
 type UserService struct {
-	notificationFacade *facade.NotificationFacade
-	repository         *UserRepository
-	roleService        *permissions.RoleService
-	rightService       *permissions.RightService
-	conf               *passport.Config
-	log                *zap.Logger
+	notificationFacade     *facade.NotificationFacade
+	repository             *UserRepository
+	refreshTokenRepository *RefreshTokenRepository
+	revokedTokenRepository *RevokedTokenRepository
+	mfaChallengeRepository *MfaChallengeRepository
+	lockoutService         *LockoutService
+	sessionService         *SessionService
+	auditService           *audit.AuditService
+	riskService            *RiskService
+	passwordPolicy         *passport.PasswordPolicy
+	passwordHasher         *passport.PasswordHasher
+	keyManager             *passport.KeyManager
+	clientService          *clients.ClientService
+	roleService            *permissions.RoleService
+	rightService           *permissions.RightService
+	conf                   *passport.Config
+	log                    *zap.Logger
 }
 
-func NewUserService(notificationFacade *facade.NotificationFacade, repository *UserRepository, roleService *permissions.RoleService, rightService *permissions.RightService, conf *passport.Config, log *zap.Logger) *UserService {
-	return &UserService{notificationFacade: notificationFacade, repository: repository, roleService: roleService, rightService: rightService, conf: conf, log: log}
+func NewUserService(notificationFacade *facade.NotificationFacade, repository *UserRepository, refreshTokenRepository *RefreshTokenRepository, revokedTokenRepository *RevokedTokenRepository, mfaChallengeRepository *MfaChallengeRepository, lockoutService *LockoutService, sessionService *SessionService, auditService *audit.AuditService, riskService *RiskService, passwordPolicy *passport.PasswordPolicy, passwordHasher *passport.PasswordHasher, keyManager *passport.KeyManager, clientService *clients.ClientService, roleService *permissions.RoleService, rightService *permissions.RightService, conf *passport.Config, log *zap.Logger) *UserService {
+	return &UserService{notificationFacade: notificationFacade, repository: repository, refreshTokenRepository: refreshTokenRepository, revokedTokenRepository: revokedTokenRepository, mfaChallengeRepository: mfaChallengeRepository, lockoutService: lockoutService, sessionService: sessionService, auditService: auditService, riskService: riskService, passwordPolicy: passwordPolicy, passwordHasher: passwordHasher, keyManager: keyManager, clientService: clientService, roleService: roleService, rightService: rightService, conf: conf, log: log}
 }
 
 func (s *UserService) CreateUser(ctx context.Context, username string, email string, password string, r string, isAdmin bool, rr []string) (*User, error) {
@@ -54,7 +69,15 @@ func (s *UserService) CreateUser(ctx context.Context, username string, email str
 		return nil, eris.New("Email already exists")
 	}
 
-	hashedPassword, err := passport.Hash(password)
+	// users signing in with social provider have no password
+	if password != "" {
+		err = s.ValidatePassword(password, username, email)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hashedPassword, err := s.passwordHasher.Hash(password)
 	if err != nil {
 		return nil, eris.Wrap(err, "Could not hash password")
 	}
@@ -68,18 +91,9 @@ func (s *UserService) CreateUser(ctx context.Context, username string, email str
 		return nil, eris.New("could not find role")
 	}
 
-	rights := make([]*permissions.Right, 0)
-	for _, rightName := range rr {
-		right, err := s.rightService.GetByName(ctx, rightName)
-		if err != nil {
-			return nil, eris.Wrap(err, "could not get right by name")
-		}
-
-		if right == nil {
-			return nil, eris.New("could not find right")
-		}
-
-		rights = append(rights, right)
+	rights, err := s.getRightsByNames(ctx, rr)
+	if err != nil {
+		return nil, err
 	}
 
 	token, err := generateCode(32)
@@ -100,6 +114,8 @@ func (s *UserService) CreateUser(ctx context.Context, username string, email str
 
 	newUser.ID = ID
 
+	s.auditService.Success(ctx, audit.ActionUserCreate, newUser.ID.Hex(), map[string]string{"role": role.Name})
+
 	if !isAdmin {
 		s.notificationFacade.Publish(ctx, "email", facade.Message{
 			Topic:     "email_verification",
@@ -114,6 +130,24 @@ func (s *UserService) CreateUser(ctx context.Context, username string, email str
 	return newUser, nil
 }
 
+func (s *UserService) getRightsByNames(ctx context.Context, rr []string) ([]*permissions.Right, error) {
+	rights := make([]*permissions.Right, 0)
+	for _, rightName := range rr {
+		right, err := s.rightService.GetByName(ctx, rightName)
+		if err != nil {
+			return nil, eris.Wrap(err, "could not get right by name")
+		}
+
+		if right == nil {
+			return nil, eris.New("could not find right")
+		}
+
+		rights = append(rights, right)
+	}
+
+	return rights, nil
+}
+
 func (s *UserService) VerifyEmail(ctx context.Context, token string) error {
 	err := s.repository.Verify(ctx, token)
 	if err != nil {
@@ -123,7 +157,9 @@ func (s *UserService) VerifyEmail(ctx context.Context, token string) error {
 	return nil
 }
 
-func (s *UserService) UpdateUser(ctx context.Context, id primitive.ObjectID, email string, username string, isActive bool, changePassword bool) (*User, error) {
+// UpdateUser updates the user, deactivation and changes of role or rights revoke all tokens issued so far.
+// Empty role and nil rights leave them unchanged.
+func (s *UserService) UpdateUser(ctx context.Context, id primitive.ObjectID, email string, username string, isActive bool, changePassword bool, r string, rr []string) (*User, error) {
 
 	u := &User{}
 	found, err := s.repository.GetById(ctx, id, u)
@@ -143,8 +179,42 @@ func (s *UserService) UpdateUser(ctx context.Context, id primitive.ObjectID, ema
 		u.Username = username
 	}
 
+	revokeTokens := u.IsActive && !isActive
 	u.IsActive = isActive
 
+	if r != "" {
+		role, err := s.roleService.GetByName(ctx, r)
+		if err != nil {
+			return nil, eris.Wrap(err, "could not get role by name")
+		}
+
+		if role == nil {
+			return nil, eris.New("could not find role")
+		}
+
+		if u.Role != role.ID {
+			u.Role = role.ID
+			revokeTokens = true
+		}
+	}
+
+	if rr != nil {
+		rights, err := s.getRightsByNames(ctx, rr)
+		if err != nil {
+			return nil, err
+		}
+
+		rightsIds := make([]primitive.ObjectID, 0)
+		for _, right := range rights {
+			rightsIds = append(rightsIds, right.ID)
+		}
+
+		if !sameIds(u.Rights, rightsIds) {
+			u.Rights = rightsIds
+			revokeTokens = true
+		}
+	}
+
 	now := time.Now().UTC()
 	u.UpdatedOn = &now
 
@@ -157,165 +227,749 @@ func (s *UserService) UpdateUser(ctx context.Context, id primitive.ObjectID, ema
 		return nil, err
 	}
 
+	if revokeTokens {
+		err = s.RevokeAllTokens(ctx, u.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.auditService.Success(ctx, audit.ActionUserUpdate, u.ID.Hex(), map[string]string{"isActive": strconv.FormatBool(u.IsActive), "tokensRevoked": strconv.FormatBool(revokeTokens)})
+
 	return u, nil
 }
 
+func sameIds(a []primitive.ObjectID, b []primitive.ObjectID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, id := range a {
+		if !slices.Contains(b, id) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (s *UserService) DeleteUser(ctx context.Context, id primitive.ObjectID) (bool, error) {
 	isDeleted, err := s.repository.DeleteById(ctx, id)
 	if err != nil {
 		return false, err
 	}
 
+	if isDeleted {
+		s.auditService.Success(ctx, audit.ActionUserDelete, id.Hex(), nil)
+	}
+
 	return isDeleted, nil
 }
 
-func (s *UserService) BasicAuthToken(ctx context.Context, username, password string) (string, string, int64, error) {
+func (s *UserService) BasicAuthToken(ctx context.Context, username, password string, request TokenRequest) (*Tokens, error) {
 
-	//TODO: implement with only 1 query for optimization
-	user, err := s.GetUserByUsername(ctx, username)
+	user, err := s.AuthenticateUser(ctx, username, password, request.IP)
+	if err != nil {
+		return nil, err
+	}
+
+	request = request.Authenticated(passport.AmrPassword)
+
+	// the password is right, the user learns about unfamiliar login even when second factor stops it
+	requiresChallenge, err := s.assessLogin(ctx, user)
 	if err != nil {
-		return "", "", 0, err
+		return nil, err
+	}
+
+	if user.IsTotpEnabled {
+		return nil, s.requireMfa(ctx, user, request)
 	}
 
-	if user == nil || !passport.Match(password, user.Password) {
-		return "", "", 0, eris.New("Username or password is wrong")
+	if requiresChallenge {
+		return nil, s.requireEmailOtp(ctx, user, request)
 	}
 
-	tokenString, exp, err := s.IssueAccessToken(user)
+	return s.IssueTokens(ctx, user, request)
+}
+
+// SocialLogin issues tokens for existing user signed in with social provider. Risky login asks for emailed one-time code,
+// the provider account is what may have been taken over.
+func (s *UserService) SocialLogin(ctx context.Context, user *User) (*Tokens, error) {
+	request := TokenRequest{}.Authenticated(passport.AmrSocial)
+
+	requiresChallenge, err := s.assessLogin(ctx, user)
 	if err != nil {
-		return "", "", 0, err
+		return nil, err
 	}
 
-	refreshTokenString, err := s.IssueRefreshToken(user)
+	if user.IsTotpEnabled {
+		return nil, s.requireMfa(ctx, user, request)
+	}
+
+	if requiresChallenge {
+		return nil, s.requireEmailOtp(ctx, user, request)
+	}
+
+	return s.IssueTokens(ctx, user, request)
+}
+
+// assessLogin scores interactive login of the user and alerts about unfamiliar device or place,
+// it reports whether emailed one-time code is to be asked for before tokens are issued
+func (s *UserService) assessLogin(ctx context.Context, user *User) (bool, error) {
+	assessment, err := s.riskService.Assess(ctx, user)
 	if err != nil {
-		return "", "", 0, err
+		return false, err
 	}
 
-	return tokenString, refreshTokenString, exp, nil
+	s.riskService.Alert(ctx, user, assessment)
+
+	return s.riskService.RequiresChallenge(assessment), nil
 }
 
-func (s *UserService) IssueAccessToken(user *User) (string, int64, error) {
+func (s *UserService) createMfaChallenge(ctx context.Context, user *User, request TokenRequest, emailCodeHash string) (string, error) {
+	mfaToken, err := generateCode(32)
+	if err != nil {
+		return "", eris.Wrap(err, "could not generate mfa token")
+	}
+
+	challenge := NewMfaChallenge(user.ID, passport.HashToken(mfaToken), request, s.conf.Mfa.ChallengeTTL)
+	challenge.EmailCodeHash = emailCodeHash
 
-	keyData, err := os.ReadFile(s.conf.App.PrivKeyPath)
+	_, err = s.mfaChallengeRepository.Create(ctx, challenge)
 	if err != nil {
-		return "", 0, err
+		return "", eris.Wrap(err, "could not store mfa challenge")
 	}
-	key, err := jwt.ParseRSAPrivateKeyFromPEM(keyData)
+
+	return mfaToken, nil
+}
+
+// requireEmailOtp emails one-time code for risky login of the user without second factor and returns challenge for it as MfaRequiredError
+func (s *UserService) requireEmailOtp(ctx context.Context, user *User, request TokenRequest) error {
+	code, err := generateLoginCode()
 	if err != nil {
-		return "", 0, err
+		return eris.Wrap(err, "could not generate login code")
 	}
 
-	userClaims := s.MapToUserClaims(user)
+	codeHash, err := passport.Hash(code)
+	if err != nil {
+		return eris.Wrap(err, "could not hash login code")
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, userClaims)
+	mfaToken, err := s.createMfaChallenge(ctx, user, request, codeHash)
+	if err != nil {
+		return err
+	}
 
-	tokenString, err := token.SignedString(key)
+	s.notificationFacade.Publish(ctx, "email", facade.Message{
+		Topic:     "identity",
+		Header:    "Sign In Code",
+		Body:      fmt.Sprintf("Your sign in code is: %s\nIt expires in %s. We ask for it because the sign in comes from a new device or location.", code, s.conf.Mfa.ChallengeTTL),
+		Params:    map[string]string{"email": user.Email},
+		Meta:      nil,
+		Timestamp: time.Now().Unix(),
+	}, user.ID.Hex())
+
+	return &MfaRequiredError{MfaToken: mfaToken, Methods: []string{MfaMethodEmailOtp}, ExpiresIn: int64(s.conf.Mfa.ChallengeTTL.Seconds())}
+}
+
+// requireMfa stores challenge for the pending second factor and returns it as MfaRequiredError
+func (s *UserService) requireMfa(ctx context.Context, user *User, request TokenRequest) error {
+	mfaToken, err := s.createMfaChallenge(ctx, user, request, "")
 	if err != nil {
-		return "", 0, err
+		return err
 	}
 
-	return tokenString, userClaims.ExpiresAt, nil
+	methods := []string{MfaMethodTotp}
+	if len(user.MfaRecoveryCodes) > 0 {
+		methods = append(methods, MfaMethodRecoveryCode)
+	}
+
+	return &MfaRequiredError{MfaToken: mfaToken, Methods: methods, ExpiresIn: int64(s.conf.Mfa.ChallengeTTL.Seconds())}
+}
+
+// ValidatePassword checks new password of the account against password policy, every path setting a password has to call it
+func (s *UserService) ValidatePassword(password string, username string, email string) error {
+	return s.passwordPolicy.Validate(password, username, email)
 }
 
-func (s *UserService) IssueRefreshToken(user *User) (string, error) {
-	keyData, err := os.ReadFile(s.conf.App.PrivKeyPath)
+// AuthenticateUser verifies username and password, refusing to check the password while login from the account or address is locked out
+func (s *UserService) AuthenticateUser(ctx context.Context, username, password string, ip string) (*User, error) {
+	err := s.lockoutService.Check(ctx, username, ip)
 	if err != nil {
-		return "", err
+		s.recordLoginFailure(ctx, nil, username, "locked out")
+		return nil, err
 	}
 
-	key, err := jwt.ParseRSAPrivateKeyFromPEM(keyData)
+	//TODO: implement with only 1 query for optimization
+	user, err := s.GetUserByUsername(ctx, username)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	userClaims := s.MapToUserClaims(user)
+	if user == nil || !s.passwordHasher.Verify(password, user.Password) {
+		err = s.lockoutService.RegisterFailure(ctx, user, username, ip)
+		if err != nil {
+			s.log.With(zap.Error(err)).Error("could not register failed login")
+		}
+
+		s.recordLoginFailure(ctx, user, username, "wrong username or password")
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodRS256, userClaims)
+		return nil, eris.New("Username or password is wrong")
+	}
 
-	refreshTokenString, err := refreshToken.SignedString(key)
+	err = s.lockoutService.RegisterSuccess(ctx, username)
 	if err != nil {
-		return "", err
+		s.log.With(zap.Error(err)).Error("could not reset failed logins")
 	}
 
-	return refreshTokenString, nil
+	s.rehashPassword(ctx, user, password)
+
+	return user, nil
 }
 
-// RefreshToken refreshes existing token
-func (s *UserService) RefreshToken(ctx context.Context, t string) (string, int64, error) {
-	userClaims, err := s.ValidateToken(ctx, t)
+func (s *UserService) recordLoginFailure(ctx context.Context, user *User, username string, reason string) {
+	event := &audit.Event{Action: audit.ActionLogin, Outcome: audit.OutcomeFailure, Reason: reason, Details: map[string]string{"username": username}}
+	if user != nil {
+		event.SubjectID = user.ID.Hex()
+	}
+
+	s.auditService.Record(ctx, event)
+}
+
+// rehashPassword upgrades stored hash made with outdated algorithm or parameters while the plain password is known,
+// failure is only logged because the login itself succeeded
+func (s *UserService) rehashPassword(ctx context.Context, user *User, password string) {
+	if !s.passwordHasher.NeedsRehash(user.Password) {
+		return
+	}
+
+	hashedPassword, err := s.passwordHasher.Hash(password)
 	if err != nil {
-		return "", 0, err
+		s.log.With(zap.Error(err)).Error("could not rehash password")
+		return
 	}
 
-	userId, err := primitive.ObjectIDFromHex(userClaims.Id)
+	err = s.repository.UpdatePasswordHash(ctx, user.ID, user.Password, hashedPassword)
 	if err != nil {
-		return "", 0, err
+		s.log.With(zap.Error(err)).Error("could not store rehashed password")
+		return
 	}
 
-	user, err := s.GetById(ctx, userId)
+	user.Password = hashedPassword
+}
+
+// UnlockUser lifts login lockout of the user
+func (s *UserService) UnlockUser(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	user, err := s.GetById(ctx, id)
 	if err != nil {
-		return "", 0, err
+		return false, err
+	}
+
+	if user == nil {
+		return false, nil
+	}
+
+	err = s.lockoutService.Unlock(ctx, user)
+	if err != nil {
+		return false, err
+	}
+
+	s.auditService.Success(ctx, audit.ActionUserUnlock, user.ID.Hex(), nil)
+
+	return true, nil
+}
+
+// IssueTokens starts new session and issues its access and refresh tokens, adding id token when openid scope is requested
+func (s *UserService) IssueTokens(ctx context.Context, user *User, request TokenRequest) (*Tokens, error) {
+	session, err := s.sessionService.Create(ctx, user.ID, request)
+	if err != nil {
+		return nil, err
+	}
+
+	request.SessionID = session.ID.Hex()
+
+	s.auditService.Record(ctx, &audit.Event{
+		Action:    audit.ActionLogin,
+		Outcome:   audit.OutcomeSuccess,
+		ActorID:   user.ID.Hex(),
+		SubjectID: user.ID.Hex(),
+		ClientID:  request.ClientID,
+		Details:   map[string]string{"sessionId": request.SessionID, "amr": strings.Join(request.AuthMethods, " ")},
+	})
+
+	err = s.riskService.RememberDevice(ctx, user)
+	if err != nil {
+		s.log.With(zap.Error(err)).Error("could not remember device")
+	}
+
+	accessToken, exp, err := s.IssueAccessToken(user, request)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := &Tokens{AccessToken: accessToken, Scope: request.Scope, ExpiresIn: exp}
+
+	if !request.NoRefreshToken {
+		tokens.RefreshToken, err = s.issueRefreshToken(ctx, user, session.ID, request)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	accessToken, exp, err := s.IssueAccessToken(user)
+	if request.HasScope("openid") {
+		authTime := request.AuthTime
+		if authTime.IsZero() {
+			authTime = time.Now().UTC()
+		}
+
+		tokens.IDToken, err = s.IssueIDToken(user, request, authTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tokens, nil
+}
+
+// IssueIDToken issues OpenID Connect id token for the client the user authenticated to
+func (s *UserService) IssueIDToken(user *User, request TokenRequest, authTime time.Time) (string, error) {
+	audience := request.ClientID
+	if audience == "" {
+		audience = s.conf.App.Name
+	}
+
+	now := time.Now().UTC()
+
+	idTokenClaims := &passport.IDTokenClaims{
+		Nonce:     request.Nonce,
+		AuthTime:  authTime.Unix(),
+		Amr:       request.AuthMethods,
+		SessionID: request.SessionID,
+		Acr:       passport.AuthLevel(request.AuthMethods),
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    s.conf.Token.Issuer,
+			Subject:   user.ID.Hex(),
+			Audience:  audience,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(s.conf.Token.AccessTokenTTL).Unix(),
+		},
+	}
+
+	if request.HasScope("email") {
+		idTokenClaims.Email = user.Email
+		idTokenClaims.EmailVerified = user.IsVerified
+	}
+
+	if request.HasScope("profile") {
+		idTokenClaims.PreferredUsername = user.Username
+	}
+
+	return s.keyManager.Sign(idTokenClaims)
+}
+
+func (s *UserService) IssueAccessToken(user *User, request TokenRequest) (string, int64, error) {
+
+	userClaims := s.MapToUserClaims(user, request)
+
+	tokenString, err := s.keyManager.SignAccessToken(userClaims)
 	if err != nil {
 		return "", 0, err
 	}
 
-	return accessToken, exp, err
+	return tokenString, userClaims.ExpiresAt, nil
+}
+
+func (s *UserService) issueRefreshToken(ctx context.Context, user *User, familyID primitive.ObjectID, request TokenRequest) (string, error) {
+	token, err := generateCode(32)
+	if err != nil {
+		return "", eris.Wrap(err, "could not generate refresh token")
+	}
+
+	refreshToken := NewRefreshToken(user, familyID, passport.HashToken(token), request, s.conf.Token.RefreshTokenTTL)
 
+	_, err = s.refreshTokenRepository.Create(ctx, refreshToken)
+	if err != nil {
+		return "", eris.Wrap(err, "could not store refresh token")
+	}
+
+	return token, nil
 }
 
-func (s *UserService) GetPublicKey() (*rsa.PublicKey, error) {
-	keyData, err := os.ReadFile(s.conf.App.PubKeyPath)
+// RefreshToken rotates existing refresh token, revoking the whole family when a used token is replayed
+func (s *UserService) RefreshToken(ctx context.Context, t string, clientID string) (*Tokens, error) {
+	refreshToken, err := s.refreshTokenRepository.GetByHash(ctx, passport.HashToken(t))
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get refresh token")
+	}
+
+	if refreshToken == nil || refreshToken.ClientID != clientID {
+		return nil, eris.New("Refresh token is invalid")
+	}
+
+	err = refreshToken.verify(time.Now().UTC())
+	if errors.Is(err, errRefreshTokenReused) {
+		return nil, s.revokeReusedFamily(ctx, refreshToken)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	isMarked, err := s.refreshTokenRepository.MarkUsed(ctx, refreshToken.ID)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not mark refresh token as used")
+	}
+
+	if !isMarked {
+		return nil, s.revokeReusedFamily(ctx, refreshToken)
+	}
+
+	user, err := s.GetById(ctx, refreshToken.UserID)
 	if err != nil {
 		return nil, err
 	}
 
-	return jwt.ParseRSAPublicKeyFromPEM(keyData)
+	if user == nil || !user.IsActive || !user.HasTokenVersion(refreshToken.TokenVersion) {
+		return nil, eris.New("Refresh token is invalid")
+	}
+
+	// family id is the session id, signed out session can not be refreshed
+	isActive, err := s.sessionService.Touch(ctx, refreshToken.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isActive {
+		return nil, eris.New("Refresh token is invalid")
+	}
+
+	request := TokenRequest{
+		ClientID:    refreshToken.ClientID,
+		Scope:       refreshToken.Scope,
+		AuthTime:    refreshToken.AuthTime,
+		AuthMethods: refreshToken.AuthMethods,
+		SessionID:   refreshToken.FamilyID.Hex(),
+	}
+
+	accessToken, exp, err := s.IssueAccessToken(user, request)
+	if err != nil {
+		return nil, err
+	}
+
+	newRefreshToken, err := s.issueRefreshToken(ctx, user, refreshToken.FamilyID, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{AccessToken: accessToken, RefreshToken: newRefreshToken, Scope: request.Scope, ExpiresIn: exp}, nil
 }
 
+func (s *UserService) revokeReusedFamily(ctx context.Context, refreshToken *RefreshToken) error {
+	s.log.Warn("refresh token reuse detected", zap.String("userId", refreshToken.UserID.Hex()), zap.String("familyId", refreshToken.FamilyID.Hex()))
+
+	_, err := s.sessionService.Revoke(ctx, refreshToken.UserID, refreshToken.FamilyID)
+	if err != nil {
+		return err
+	}
+
+	err = s.refreshTokenRepository.RevokeFamily(ctx, refreshToken.FamilyID)
+	if err != nil {
+		return eris.Wrap(err, "could not revoke refresh token family")
+	}
+
+	return eris.New("Refresh token is invalid")
+}
+
+// ValidateToken accepts access tokens issued for this service, id tokens and tokens exchanged for other audiences are rejected
 func (s *UserService) ValidateToken(ctx context.Context, t string) (*passport.UserClaims, error) {
-	key, err := s.GetPublicKey()
+	claims, err := s.parseAccessToken(ctx, t)
 	if err != nil {
 		return nil, err
 	}
 
-	token, err := jwt.ParseWithClaims(t, &passport.UserClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return key, nil
-	})
+	// exchanged tokens targeted at other services are not accepted by this one
+	if claims.Audience != "" && claims.Audience != s.conf.Token.Issuer {
+		return nil, eris.New("Token is invalid")
+	}
+
+	return claims, nil
+}
+
+// parseAccessToken checks signature, type and revocation of access token issued for any audience
+func (s *UserService) parseAccessToken(ctx context.Context, t string) (*passport.UserClaims, error) {
+	token, err := jwt.ParseWithClaims(t, &passport.UserClaims{}, s.keyManager.Keyfunc)
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*passport.UserClaims); ok && token.Valid {
+	claims, ok := token.Claims.(*passport.UserClaims)
+	if !ok || !token.Valid || token.Header["typ"] != passport.AccessTokenType {
+		return nil, eris.New("Token is invalid")
+	}
+
+	isRevoked, err := s.revokedTokenRepository.IsRevoked(ctx, claims.Id)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not check token revocation")
+	}
+
+	if isRevoked {
+		return nil, eris.New("Token is revoked")
+	}
+
+	// machine tokens have no session, every user token is bound to one
+	if claims.IsClient {
 		return claims, nil
 	}
 
-	return nil, nil
+	isActive, err := s.sessionService.IsActive(ctx, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isActive {
+		return nil, eris.New("Session is revoked")
+	}
+
+	return claims, nil
 }
 
-func (s *UserService) GetUserByToken(ctx context.Context, t string) (*User, error) {
-	key, err := s.GetPublicKey()
+// RevokeToken revokes access or refresh token of the client as described in RFC 7009. Unknown tokens and tokens
+// of other clients are ignored, the hint only decides which token type is looked up first.
+func (s *UserService) RevokeToken(ctx context.Context, t string, tokenTypeHint string, clientID string) error {
+	revokers := []func(context.Context, string, string) (bool, error){s.revokeClientRefreshToken, s.revokeClientAccessToken}
+	if tokenTypeHint == "access_token" {
+		revokers[0], revokers[1] = revokers[1], revokers[0]
+	}
+
+	for _, revoke := range revokers {
+		isFound, err := revoke(ctx, t, clientID)
+		if err != nil || isFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revokeClientRefreshToken revokes family of the refresh token issued to the client, it reports whether t is a refresh token
+func (s *UserService) revokeClientRefreshToken(ctx context.Context, t string, clientID string) (bool, error) {
+	refreshToken, err := s.refreshTokenRepository.GetByHash(ctx, passport.HashToken(t))
+	if err != nil {
+		return false, eris.Wrap(err, "could not get refresh token")
+	}
+
+	if refreshToken == nil {
+		return false, nil
+	}
+
+	if refreshToken.ClientID != clientID {
+		s.log.Info("client tried to revoke refresh token of another client", zap.String("clientId", clientID))
+		return true, nil
+	}
+
+	err = s.refreshTokenRepository.DeleteFamily(ctx, refreshToken.FamilyID)
+	if err != nil {
+		return false, eris.Wrap(err, "could not delete refresh token family")
+	}
+
+	return true, nil
+}
+
+// revokeClientAccessToken revokes access token issued to the client, it reports whether t is a live access token
+func (s *UserService) revokeClientAccessToken(ctx context.Context, t string, clientID string) (bool, error) {
+	claims, err := s.parseAccessToken(ctx, t)
+	if err != nil {
+		return false, nil
+	}
+
+	if claims.ClientID != clientID {
+		s.log.Info("client tried to revoke access token of another client", zap.String("clientId", clientID))
+		return true, nil
+	}
+
+	return true, s.revokeAccessToken(ctx, claims)
+}
+
+// Logout revokes the access token with its session and, when given, the refresh token family of the same user
+func (s *UserService) Logout(ctx context.Context, accessToken string, refreshToken string) error {
+	claims, err := s.ValidateToken(ctx, accessToken)
+	if err != nil {
+		return err
+	}
+
+	err = s.revokeAccessToken(ctx, claims)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(claims.SessionID)
+	if err == nil {
+		userID, _ := primitive.ObjectIDFromHex(claims.Subject)
+
+		_, err = s.sessionService.Revoke(ctx, userID, sessionID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if refreshToken != "" {
+		_, err = s.revokeRefreshToken(ctx, refreshToken, claims.Subject)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.auditService.Success(ctx, audit.ActionLogout, claims.Subject, map[string]string{"sessionId": claims.SessionID})
+
+	return nil
+}
+
+func (s *UserService) revokeAccessToken(ctx context.Context, claims *passport.UserClaims) error {
+	revokedToken := NewRevokedToken(claims.Id, claims.Subject, time.Unix(claims.ExpiresAt, 0).UTC())
+
+	err := s.revokedTokenRepository.Revoke(ctx, revokedToken)
+	if err != nil {
+		return eris.Wrap(err, "could not revoke access token")
+	}
+
+	return nil
+}
+
+func (s *UserService) revokeRefreshToken(ctx context.Context, t string, subject string) (bool, error) {
+	refreshToken, err := s.refreshTokenRepository.GetByHash(ctx, passport.HashToken(t))
+	if err != nil {
+		return false, eris.Wrap(err, "could not get refresh token")
+	}
+
+	if refreshToken == nil || (subject != "" && refreshToken.UserID.Hex() != subject) {
+		return false, nil
+	}
+
+	err = s.refreshTokenRepository.DeleteFamily(ctx, refreshToken.FamilyID)
+	if err != nil {
+		return false, eris.Wrap(err, "could not delete refresh token family")
+	}
+
+	return true, nil
+}
+
+// Introspect describes token state as defined in RFC 7662, using current user role and rights
+func (s *UserService) Introspect(ctx context.Context, t string) (*responses.IntrospectionResponse, error) {
+	inactive := &responses.IntrospectionResponse{Active: false}
+
+	claims, err := s.parseAccessToken(ctx, t)
+	if err != nil {
+		return inactive, nil
+	}
+
+	if claims.IsClient {
+		return s.introspectClientToken(ctx, claims)
+	}
+
+	userId, err := primitive.ObjectIDFromHex(claims.Subject)
+	if err != nil {
+		return inactive, nil
+	}
+
+	user, err := s.GetById(ctx, userId)
 	if err != nil {
 		return nil, err
 	}
 
-	token, err := jwt.ParseWithClaims(t, &passport.UserClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	if user == nil || !user.IsActive || !user.IsVerified || !user.HasTokenVersion(claims.TokenVersion) {
+		return inactive, nil
+	}
+
+	role, rights, err := s.LoadPermisions(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	// exchanged tokens carry reduced rights, report only those the user still has
+	rightsNames := make([]string, 0)
+	for _, right := range rights {
+		if claims.Act != nil && !slices.Contains(claims.Rights, right.ID.Hex()) {
+			continue
 		}
-		return key, nil
-	})
+
+		rightsNames = append(rightsNames, right.Name)
+	}
+
+	return &responses.IntrospectionResponse{
+		Active:    true,
+		Sub:       claims.Subject,
+		Username:  user.Username,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		TokenType: "Bearer",
+		Exp:       claims.ExpiresAt,
+		Iat:       claims.IssuedAt,
+		Jti:       claims.Id,
+		Role:      role.Name,
+		Rights:    rightsNames,
+		Aud:       claims.Audience,
+		AuthTime:  claims.AuthTime,
+		Amr:       claims.Amr,
+		Acr:       claims.Acr,
+		Act:       mapToActorResponse(claims.Act),
+	}, nil
+}
+
+func mapToActorResponse(actor *passport.Actor) *responses.ActorResponse {
+	if actor == nil {
+		return nil
+	}
+
+	return &responses.ActorResponse{Sub: actor.Subject, ClientID: actor.ClientID, Act: mapToActorResponse(actor.Act)}
+}
+
+func (s *UserService) introspectClientToken(ctx context.Context, claims *passport.UserClaims) (*responses.IntrospectionResponse, error) {
+	client, err := s.clientService.GetByClientID(ctx, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		return &responses.IntrospectionResponse{Active: false}, nil
+	}
+
+	role, rights, err := s.clientService.LoadPermissions(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	rightsNames := make([]string, 0)
+	for _, right := range rights {
+		rightsNames = append(rightsNames, right.Name)
+	}
+
+	response := &responses.IntrospectionResponse{
+		Active:    true,
+		Sub:       claims.Subject,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		TokenType: "Bearer",
+		Exp:       claims.ExpiresAt,
+		Iat:       claims.IssuedAt,
+		Jti:       claims.Id,
+		Rights:    rightsNames,
+	}
+
+	if role != nil {
+		response.Role = role.Name
+	}
+
+	return response, nil
+}
+
+func (s *UserService) GetUserByToken(ctx context.Context, t string) (*User, error) {
+	token, err := jwt.ParseWithClaims(t, &passport.UserClaims{}, s.keyManager.Keyfunc)
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*passport.UserClaims); ok && token.Valid {
-		userId, err := primitive.ObjectIDFromHex(claims.Id)
+		userId, err := primitive.ObjectIDFromHex(claims.Subject)
 		if err != nil {
 			return nil, err
 		}
@@ -508,10 +1162,16 @@ func (s *UserService) ResetPassword(ctx context.Context, email string, code stri
 	}
 
 	if !passport.Match(code, existingCodeHash) {
+		s.auditService.Failure(ctx, audit.ActionPasswordReset, u.ID.Hex(), "recovery code does not match")
 		return eris.New("Provided recovery code does not match")
 	}
 
-	hashedPassword, err := passport.Hash(newPassword)
+	err = s.ValidatePassword(newPassword, u.Username, u.Email)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.passwordHasher.Hash(newPassword)
 	if err != nil {
 		return err
 	}
@@ -521,7 +1181,19 @@ func (s *UserService) ResetPassword(ctx context.Context, email string, code stri
 		return err
 	}
 
-	return nil
+	s.auditService.Success(ctx, audit.ActionPasswordReset, u.ID.Hex(), nil)
+
+	return s.RevokeAllTokens(ctx, u.ID)
+}
+
+// RevokeAllTokens invalidates every access and refresh token of the user and signs the user out everywhere
+func (s *UserService) RevokeAllTokens(ctx context.Context, id primitive.ObjectID) error {
+	err := s.repository.IncrementTokenVersion(ctx, id)
+	if err != nil {
+		return eris.Wrap(err, "could not increment token version")
+	}
+
+	return s.sessionService.RevokeAll(ctx, id)
 }
 
 func generateCode(size int) (string, error) {
@@ -564,7 +1236,7 @@ func (s *UserService) MapToUserResponse(ctx context.Context, u *User) (*response
 	}, nil
 }
 
-func (s *UserService) MapToUserClaims(u *User) *passport.UserClaims {
+func (s *UserService) MapToUserClaims(u *User, request TokenRequest) *passport.UserClaims {
 	rightsIds := make([]string, 0)
 	for _, right := range u.Rights {
 		rightsIds = append(rightsIds, right.Hex())
@@ -576,15 +1248,30 @@ func (s *UserService) MapToUserClaims(u *User) *passport.UserClaims {
 		return nil
 	}
 
+	now := time.Now().UTC()
+
 	userClaims := &passport.UserClaims{
-		RoleId: u.Role.Hex(),
-		Role:   role.Name,
-		Rights: rightsIds,
+		RoleId:       u.Role.Hex(),
+		Role:         role.Name,
+		Rights:       rightsIds,
+		Scope:        request.Scope,
+		ClientID:     request.ClientID,
+		Amr:          request.AuthMethods,
+		SessionID:    request.SessionID,
+		TokenVersion: u.TokenVersion,
 		StandardClaims: jwt.StandardClaims{
-			Id:        u.ID.Hex(),
-			ExpiresAt: time.Now().Add(time.Hour).UTC().Unix(),
+			Id:        primitive.NewObjectID().Hex(),
+			Issuer:    s.conf.Token.Issuer,
+			Subject:   u.ID.Hex(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(s.conf.Token.AccessTokenTTL).Unix(),
 		},
 	}
 
+	if !request.AuthTime.IsZero() {
+		userClaims.AuthTime = request.AuthTime.Unix()
+		userClaims.Acr = passport.AuthLevel(request.AuthMethods)
+	}
+
 	return userClaims
 }
@@ -0,0 +1,60 @@
+package users
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebAuthnCredential is passkey registered by the user, public key is stored as COSE_Key
+type WebAuthnCredential struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	CreatedOn    time.Time          `bson:"createdOn"`
+	LastUsedOn   *time.Time         `bson:"lastUsedOn,omitempty"`
+	UserID       primitive.ObjectID `bson:"userId"`
+	CredentialID string             `bson:"credentialId"`
+	PublicKey    []byte             `bson:"publicKey"`
+	SignCount    uint32             `bson:"signCount"`
+	AAGUID       []byte             `bson:"aaguid,omitempty"`
+	Transports   []string           `bson:"transports,omitempty"`
+	Name         string             `bson:"name,omitempty"`
+}
+
+func NewWebAuthnCredential(userID primitive.ObjectID, credentialID string, publicKey []byte, signCount uint32, aaguid []byte, transports []string, name string) *WebAuthnCredential {
+	return &WebAuthnCredential{
+		ID:           primitive.NewObjectID(),
+		CreatedOn:    time.Now().UTC(),
+		UserID:       userID,
+		CredentialID: credentialID,
+		PublicKey:    publicKey,
+		SignCount:    signCount,
+		AAGUID:       aaguid,
+		Transports:   transports,
+		Name:         name,
+	}
+}
+
+// WebAuthnChallenge is single-use challenge of registration or login ceremony
+type WebAuthnChallenge struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	CreatedOn time.Time          `bson:"createdOn"`
+	ExpiresOn time.Time          `bson:"expiresOn"`
+	Challenge string             `bson:"challenge"`
+	Ceremony  string             `bson:"ceremony"`
+	UserID    primitive.ObjectID `bson:"userId,omitempty"`
+	Scope     string             `bson:"scope,omitempty"`
+}
+
+func NewWebAuthnChallenge(challenge string, ceremony string, userID primitive.ObjectID, scope string, ttl time.Duration) *WebAuthnChallenge {
+	now := time.Now().UTC()
+
+	return &WebAuthnChallenge{
+		ID:        primitive.NewObjectID(),
+		CreatedOn: now,
+		ExpiresOn: now.Add(ttl),
+		Challenge: challenge,
+		Ceremony:  ceremony,
+		UserID:    userID,
+		Scope:     scope,
+	}
+}
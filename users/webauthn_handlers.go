@@ -0,0 +1,193 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/georgi-georgiev/blunder"
+	"github.com/georgi-georgiev/passport/payloads"
+	"github.com/georgi-georgiev/passport/responses"
+	"github.com/gin-gonic/gin"
+)
+
+// BeginWebAuthnRegistrationHandler godoc
+// @Summary Begin passkey registration
+// @Description Returns options for navigator.credentials.create
+// @Tags identity
+// @Produce  json
+// @Security OAuth2Application
+// @Success 200 {object} responses.CredentialCreationOptionsResponse
+// @Router /webauthn/register/begin [post]
+func (h *UserHandlers) BeginWebAuthnRegistration(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	options, err := h.webAuthnService.BeginRegistration(c.Request.Context(), user)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishWebAuthnRegistrationHandler godoc
+// @Summary Finish passkey registration
+// @Description Verifies attestation returned by navigator.credentials.create and stores the passkey
+// @Tags identity
+// @Accept  json
+// @Produce  json
+// @Security OAuth2Application
+// @Param payload body payloads.WebAuthnRegistrationPayload true "payload"
+// @Success 201 {object} responses.WebAuthnCredentialResponse
+// @Failure      400  {object}  blunder.HTTPErrorResponse
+// @Router /webauthn/register/finish [post]
+func (h *UserHandlers) FinishWebAuthnRegistration(c *gin.Context) {
+	var payload payloads.WebAuthnRegistrationPayload
+	errors := h.blunder.BindJson(c.Request, &payload)
+	if errors != nil {
+		for _, err := range errors {
+			h.blunder.GinAdd(c, err)
+		}
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	credential, err := h.webAuthnService.FinishRegistration(c.Request.Context(), user, payload)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, MapToWebAuthnCredentialResponse(credential))
+}
+
+// BeginWebAuthnLoginHandler godoc
+// @Summary Begin passkey login
+// @Description Returns options for navigator.credentials.get, username is optional for discoverable passkeys
+// @Tags identity
+// @Accept  json
+// @Produce  json
+// @Param payload body payloads.WebAuthnLoginBeginPayload false "payload"
+// @Success 200 {object} responses.CredentialRequestOptionsResponse
+// @Router /webauthn/login/begin [post]
+func (h *UserHandlers) BeginWebAuthnLogin(c *gin.Context) {
+	var payload payloads.WebAuthnLoginBeginPayload
+	if c.Request.ContentLength > 0 {
+		errors := h.blunder.BindJson(c.Request, &payload)
+		if errors != nil {
+			for _, err := range errors {
+				h.blunder.GinAdd(c, err)
+			}
+			return
+		}
+	}
+
+	options, err := h.webAuthnService.BeginLogin(c.Request.Context(), payload.Username, payload.Scope)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishWebAuthnLoginHandler godoc
+// @Summary Finish passkey login
+// @Description Verifies assertion returned by navigator.credentials.get and issues tokens
+// @Tags identity
+// @Accept  json
+// @Produce  json
+// @Param payload body payloads.WebAuthnLoginPayload true "payload"
+// @Success 200 {object} responses.TokenResponse
+// @Failure      400  {object}  blunder.HTTPErrorResponse
+// @Router /webauthn/login/finish [post]
+func (h *UserHandlers) FinishWebAuthnLogin(c *gin.Context) {
+	var payload payloads.WebAuthnLoginPayload
+	errors := h.blunder.BindJson(c.Request, &payload)
+	if errors != nil {
+		for _, err := range errors {
+			h.blunder.GinAdd(c, err)
+		}
+		return
+	}
+
+	tokens, err := h.webAuthnService.FinishLogin(c.Request.Context(), payload)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, responses.TokenResponse{TokenType: "Bearer", AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken, IDToken: tokens.IDToken, ExpiresIn: tokens.ExpiresIn})
+}
+
+// GetWebAuthnCredentialsHandler godoc
+// @Summary Get passkeys
+// @Description Lists passkeys registered by the authenticated user
+// @Tags identity
+// @Produce  json
+// @Security OAuth2Application
+// @Success 200 {array} responses.WebAuthnCredentialResponse
+// @Router /webauthn/credentials [get]
+func (h *UserHandlers) GetWebAuthnCredentials(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	credentials, err := h.webAuthnService.GetCredentials(c.Request.Context(), user.ID)
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	response := make([]responses.WebAuthnCredentialResponse, 0)
+	for _, credential := range credentials {
+		response = append(response, MapToWebAuthnCredentialResponse(credential))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteWebAuthnCredentialHandler godoc
+// @Summary Delete passkey
+// @Description Removes passkey of the authenticated user
+// @Tags identity
+// @Security OAuth2Application
+// @Param credentialId path string true "credential id"
+// @Success 204
+// @Failure      404  {object}  blunder.HTTPErrorResponse
+// @Router /webauthn/credentials/{credentialId} [delete]
+func (h *UserHandlers) DeleteWebAuthnCredential(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	isDeleted, err := h.webAuthnService.DeleteCredential(c.Request.Context(), user.ID, c.Param("credentialId"))
+	if err != nil {
+		h.blunder.GinAdd(c, err)
+		return
+	}
+
+	if !isDeleted {
+		c.JSON(http.StatusNotFound, blunder.NotFound())
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func MapToWebAuthnCredentialResponse(credential *WebAuthnCredential) responses.WebAuthnCredentialResponse {
+	return responses.WebAuthnCredentialResponse{
+		ID:         credential.CredentialID,
+		Name:       credential.Name,
+		CreatedOn:  credential.CreatedOn,
+		LastUsedOn: credential.LastUsedOn,
+	}
+}
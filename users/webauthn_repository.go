@@ -0,0 +1,132 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgi-georgiev/passport"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WebAuthnCredentialRepository struct {
+	*passport.MongoRepository
+}
+
+func NewWebAuthnCredentialRepository(client *mongo.Client, conf *passport.Config) *WebAuthnCredentialRepository {
+	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "webauthn_credentials")
+
+	credentialIDIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "credentialId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	userIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "userId", Value: 1}},
+	}
+
+	_, err := repository.Collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{credentialIDIndex, userIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	return &WebAuthnCredentialRepository{repository}
+}
+
+func (r *WebAuthnCredentialRepository) GetByCredentialID(ctx context.Context, credentialID string) (*WebAuthnCredential, error) {
+	result := &WebAuthnCredential{}
+
+	err := r.Collection.FindOne(ctx, bson.M{"credentialId": credentialID}).Decode(result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *WebAuthnCredentialRepository) GetByUserID(ctx context.Context, userID primitive.ObjectID) ([]*WebAuthnCredential, error) {
+	cursor, err := r.Collection.Find(ctx, bson.M{"userId": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	credentials := make([]*WebAuthnCredential, 0)
+
+	err = cursor.All(ctx, &credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// UpdateSignCount stores counter of the last assertion, returning false when a newer one was stored concurrently
+func (r *WebAuthnCredentialRepository) UpdateSignCount(ctx context.Context, id primitive.ObjectID, previous uint32, signCount uint32) (bool, error) {
+	filter := bson.M{"_id": id, "signCount": previous}
+	update := bson.M{"$set": bson.M{"signCount": signCount, "lastUsedOn": time.Now().UTC()}}
+
+	ur, err := r.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return ur.ModifiedCount > 0, nil
+}
+
+func (r *WebAuthnCredentialRepository) DeleteForUser(ctx context.Context, userID primitive.ObjectID, credentialID string) (bool, error) {
+	dr, err := r.Collection.DeleteOne(ctx, bson.M{"userId": userID, "credentialId": credentialID})
+	if err != nil {
+		return false, err
+	}
+
+	return dr.DeletedCount > 0, nil
+}
+
+type WebAuthnChallengeRepository struct {
+	*passport.MongoRepository
+}
+
+func NewWebAuthnChallengeRepository(client *mongo.Client, conf *passport.Config) *WebAuthnChallengeRepository {
+	repository := passport.NewMongoRepository(client, conf.Mongo.Dbname, "webauthn_challenges")
+
+	challengeIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "challenge", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	expirationIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresOn", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	_, err := repository.Collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{challengeIndex, expirationIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	return &WebAuthnChallengeRepository{repository}
+}
+
+// Take removes and returns unexpired challenge of the ceremony so it can not be used twice
+func (r *WebAuthnChallengeRepository) Take(ctx context.Context, challenge string, ceremony string) (*WebAuthnChallenge, error) {
+	result := &WebAuthnChallenge{}
+
+	filter := bson.M{"challenge": challenge, "ceremony": ceremony, "expiresOn": bson.M{"$gt": time.Now().UTC()}}
+
+	err := r.Collection.FindOneAndDelete(ctx, filter).Decode(result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,304 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+
+	"github.com/georgi-georgiev/passport"
+	"github.com/georgi-georgiev/passport/payloads"
+	"github.com/georgi-georgiev/passport/pkg/webauthn"
+	"github.com/georgi-georgiev/passport/responses"
+	"github.com/rotisserie/eris"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type WebAuthnService struct {
+	userService          *UserService
+	credentialRepository *WebAuthnCredentialRepository
+	challengeRepository  *WebAuthnChallengeRepository
+	conf                 *passport.Config
+	log                  *zap.Logger
+}
+
+func NewWebAuthnService(userService *UserService, credentialRepository *WebAuthnCredentialRepository, challengeRepository *WebAuthnChallengeRepository, conf *passport.Config, log *zap.Logger) *WebAuthnService {
+	return &WebAuthnService{userService: userService, credentialRepository: credentialRepository, challengeRepository: challengeRepository, conf: conf, log: log}
+}
+
+// BeginRegistration issues challenge and options for navigator.credentials.create
+func (s *WebAuthnService) BeginRegistration(ctx context.Context, user *User) (*responses.CredentialCreationOptionsResponse, error) {
+	challenge, err := s.newChallenge(ctx, webauthn.CeremonyCreate, user.ID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.credentialRepository.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get webauthn credentials")
+	}
+
+	params := make([]responses.CredentialParameterResponse, 0)
+	for _, alg := range webauthn.Algorithms {
+		params = append(params, responses.CredentialParameterResponse{Type: "public-key", Alg: alg})
+	}
+
+	return &responses.CredentialCreationOptionsResponse{
+		Challenge: challenge,
+		RP:        responses.RelyingPartyResponse{ID: s.conf.WebAuthn.RPID, Name: s.conf.WebAuthn.RPName},
+		User: responses.WebAuthnUserResponse{
+			ID:          webauthn.Encoding.EncodeToString(user.ID[:]),
+			Name:        user.Username,
+			DisplayName: user.Email,
+		},
+		PubKeyCredParams:   params,
+		Timeout:            s.conf.WebAuthn.ChallengeTTL.Milliseconds(),
+		ExcludeCredentials: mapToCredentialDescriptors(credentials),
+		AuthenticatorSelection: responses.AuthenticatorSelectionResponse{
+			ResidentKey:      "preferred",
+			UserVerification: s.userVerification(),
+		},
+		Attestation: "none",
+	}, nil
+}
+
+// FinishRegistration verifies attestation and stores the new credential for the user
+func (s *WebAuthnService) FinishRegistration(ctx context.Context, user *User, payload payloads.WebAuthnRegistrationPayload) (*WebAuthnCredential, error) {
+	clientDataJSON, err := webauthn.Encoding.DecodeString(payload.Response.ClientDataJSON)
+	if err != nil {
+		return nil, eris.New("Client data is malformed")
+	}
+
+	attestation, err := webauthn.Encoding.DecodeString(payload.Response.AttestationObject)
+	if err != nil {
+		return nil, eris.New("Attestation object is malformed")
+	}
+
+	challenge, err := s.takeChallenge(ctx, clientDataJSON, webauthn.CeremonyCreate)
+	if err != nil {
+		return nil, err
+	}
+
+	if challenge.UserID != user.ID {
+		return nil, eris.New("Challenge is invalid or expired")
+	}
+
+	verified, err := webauthn.VerifyRegistration(attestation, clientDataJSON, s.expectation(challenge.Challenge))
+	if err != nil {
+		s.log.With(zap.Error(err)).Info("could not verify webauthn registration")
+		return nil, eris.New("Passkey verification failed")
+	}
+
+	credentialID := webauthn.Encoding.EncodeToString(verified.ID)
+
+	existing, err := s.credentialRepository.GetByCredentialID(ctx, credentialID)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get webauthn credential")
+	}
+
+	if existing != nil {
+		return nil, eris.New("Passkey is already registered")
+	}
+
+	credential := NewWebAuthnCredential(user.ID, credentialID, verified.PublicKey, verified.SignCount, verified.AAGUID, payload.Response.Transports, payload.Name)
+
+	_, err = s.credentialRepository.Create(ctx, credential)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not store webauthn credential")
+	}
+
+	return credential, nil
+}
+
+// BeginLogin issues challenge and options for navigator.credentials.get, without username discoverable credentials are expected
+func (s *WebAuthnService) BeginLogin(ctx context.Context, username string, scope string) (*responses.CredentialRequestOptionsResponse, error) {
+	userID := primitive.NilObjectID
+	credentials := make([]*WebAuthnCredential, 0)
+
+	if username != "" {
+		user, err := s.userService.GetUserByUsername(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+
+		// unknown users get empty allow list so the response does not reveal registered usernames
+		if user != nil {
+			userID = user.ID
+
+			credentials, err = s.credentialRepository.GetByUserID(ctx, user.ID)
+			if err != nil {
+				return nil, eris.Wrap(err, "could not get webauthn credentials")
+			}
+		}
+	}
+
+	challenge, err := s.newChallenge(ctx, webauthn.CeremonyGet, userID, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responses.CredentialRequestOptionsResponse{
+		Challenge:        challenge,
+		RPID:             s.conf.WebAuthn.RPID,
+		Timeout:          s.conf.WebAuthn.ChallengeTTL.Milliseconds(),
+		AllowCredentials: mapToCredentialDescriptors(credentials),
+		UserVerification: s.userVerification(),
+	}, nil
+}
+
+// FinishLogin verifies assertion of a registered credential and issues tokens for its owner
+func (s *WebAuthnService) FinishLogin(ctx context.Context, payload payloads.WebAuthnLoginPayload) (*Tokens, error) {
+	clientDataJSON, err := webauthn.Encoding.DecodeString(payload.Response.ClientDataJSON)
+	if err != nil {
+		return nil, eris.New("Client data is malformed")
+	}
+
+	authenticatorData, err := webauthn.Encoding.DecodeString(payload.Response.AuthenticatorData)
+	if err != nil {
+		return nil, eris.New("Authenticator data is malformed")
+	}
+
+	signature, err := webauthn.Encoding.DecodeString(payload.Response.Signature)
+	if err != nil {
+		return nil, eris.New("Signature is malformed")
+	}
+
+	challenge, err := s.takeChallenge(ctx, clientDataJSON, webauthn.CeremonyGet)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.credentialRepository.GetByCredentialID(ctx, payload.ID)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get webauthn credential")
+	}
+
+	if credential == nil || (!challenge.UserID.IsZero() && challenge.UserID != credential.UserID) {
+		return nil, eris.New("Passkey verification failed")
+	}
+
+	if payload.Response.UserHandle != "" {
+		userHandle, err := webauthn.Encoding.DecodeString(payload.Response.UserHandle)
+		if err != nil || subtle.ConstantTimeCompare(userHandle, credential.UserID[:]) != 1 {
+			return nil, eris.New("Passkey verification failed")
+		}
+	}
+
+	signCount, err := webauthn.VerifyAssertion(credential.PublicKey, authenticatorData, clientDataJSON, signature, s.expectation(challenge.Challenge))
+	if err != nil {
+		s.log.With(zap.Error(err)).Info("could not verify webauthn assertion")
+		return nil, eris.New("Passkey verification failed")
+	}
+
+	// counter that does not grow means the authenticator may have been cloned, authenticators without counter always send zero
+	if (signCount != 0 || credential.SignCount != 0) && signCount <= credential.SignCount {
+		s.log.Warn("webauthn sign counter did not increase", zap.String("credentialId", credential.CredentialID), zap.Uint32("stored", credential.SignCount), zap.Uint32("received", signCount))
+		return nil, eris.New("Passkey verification failed")
+	}
+
+	isUpdated, err := s.credentialRepository.UpdateSignCount(ctx, credential.ID, credential.SignCount, signCount)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not update webauthn sign count")
+	}
+
+	if !isUpdated {
+		return nil, eris.New("Passkey verification failed")
+	}
+
+	user, err := s.userService.GetById(ctx, credential.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || !user.IsActive {
+		return nil, eris.New("Passkey verification failed")
+	}
+
+	// passkey is a phishing resistant multi-factor login, emailed one-time code of risky login would only weaken it
+	_, err = s.userService.assessLogin(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.userService.IssueTokens(ctx, user, TokenRequest{Scope: challenge.Scope}.Authenticated(passport.AmrWebAuthn))
+}
+
+func (s *WebAuthnService) GetCredentials(ctx context.Context, userID primitive.ObjectID) ([]*WebAuthnCredential, error) {
+	credentials, err := s.credentialRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get webauthn credentials")
+	}
+
+	return credentials, nil
+}
+
+func (s *WebAuthnService) DeleteCredential(ctx context.Context, userID primitive.ObjectID, credentialID string) (bool, error) {
+	isDeleted, err := s.credentialRepository.DeleteForUser(ctx, userID, credentialID)
+	if err != nil {
+		return false, eris.Wrap(err, "could not delete webauthn credential")
+	}
+
+	return isDeleted, nil
+}
+
+func (s *WebAuthnService) newChallenge(ctx context.Context, ceremony string, userID primitive.ObjectID, scope string) (string, error) {
+	random := make([]byte, 32)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", eris.Wrap(err, "could not generate webauthn challenge")
+	}
+
+	challenge := webauthn.Encoding.EncodeToString(random)
+
+	_, err = s.challengeRepository.Create(ctx, NewWebAuthnChallenge(challenge, ceremony, userID, scope, s.conf.WebAuthn.ChallengeTTL))
+	if err != nil {
+		return "", eris.Wrap(err, "could not store webauthn challenge")
+	}
+
+	return challenge, nil
+}
+
+// takeChallenge consumes the challenge referenced by client data so every ceremony can be completed only once
+func (s *WebAuthnService) takeChallenge(ctx context.Context, clientDataJSON []byte, ceremony string) (*WebAuthnChallenge, error) {
+	clientData, err := webauthn.ParseClientData(clientDataJSON)
+	if err != nil {
+		return nil, eris.New("Client data is malformed")
+	}
+
+	challenge, err := s.challengeRepository.Take(ctx, clientData.Challenge, ceremony)
+	if err != nil {
+		return nil, eris.Wrap(err, "could not get webauthn challenge")
+	}
+
+	if challenge == nil {
+		return nil, eris.New("Challenge is invalid or expired")
+	}
+
+	return challenge, nil
+}
+
+func (s *WebAuthnService) expectation(challenge string) webauthn.Expectation {
+	return webauthn.Expectation{
+		Challenge:               challenge,
+		RPID:                    s.conf.WebAuthn.RPID,
+		Origins:                 s.conf.WebAuthn.Origins,
+		RequireUserVerification: s.conf.WebAuthn.RequireUserVerification,
+	}
+}
+
+func (s *WebAuthnService) userVerification() string {
+	if s.conf.WebAuthn.RequireUserVerification {
+		return "required"
+	}
+
+	return "preferred"
+}
+
+func mapToCredentialDescriptors(credentials []*WebAuthnCredential) []responses.CredentialDescriptorResponse {
+	descriptors := make([]responses.CredentialDescriptorResponse, 0)
+	for _, credential := range credentials {
+		descriptors = append(descriptors, responses.CredentialDescriptorResponse{Type: "public-key", ID: credential.CredentialID, Transports: credential.Transports})
+	}
+
+	return descriptors
+}